@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -19,6 +21,45 @@ type Config struct {
 	R2BucketName      string
 	R2Endpoint        string
 	R2PublicURL       string
+	// 存储配置
+	StorageDriver     string // s3（R2）、local、memory
+	LocalStoragePath  string // local驱动的文件目录
+	StoragePublicURL  string // local/memory驱动对外访问的基础URL
+	StorageSigningKey string // 签名URL使用的HMAC密钥
+	MaxUploadSize     int64  // 单个文件上传大小上限（字节）
+	OrphanGraceHours  int64  // 孤立文件宽限期（小时），新上传的文件在此期间内不会被清理
+	// 存储配额（字节），0表示不限，可通过管理接口为单个用户调整
+	StorageQuotaContributor int64
+	StorageQuotaAdmin       int64
+	// 断点续传配置
+	MaxVideoUploadSize int64 // 视频文件大小上限（字节），仅断点续传支持视频
+	TusUploadExpiry    int64 // 断点续传会话的有效期（小时），每次上传分块后顺延
+	// 图片实时处理URL的基础地址（本服务对外访问的地址）
+	ImageBaseURL string
+	// 私有文件下载URL的有效期（分钟）
+	PrivateURLExpiry int64
+	// GeoIP配置
+	GeoIPDatabasePath string // GeoLite2-City MMDB文件路径，为空时不解析地理位置
+	GeoIPLanguage     string // 国家、城市名称使用的语言，缺少该语言时使用英文
+	GeoIPCacheSize    int64  // 解析结果的LRU缓存条数
+	// 机器人识别配置
+	BotIPRangesFile     string // 已知爬虫IP网段列表文件，每行一个CIDR
+	BotRateLimit        int64  // 同一IP每分钟的事件数超过该值视为机器人，0表示不按频率判定
+	BotSessionPageViews int64  // 会话中没有交互事件且页面访问数达到该值视为机器人，0表示不按行为判定
+	// 追踪数据中IP的保存方式：none（不保存，默认）、truncate（只保存所在网段）
+	IPStorageMode string
+	// 客户端ID的HMAC密钥，默认使用JWT密钥，修改后留存分析会把所有访客视为新访客
+	ClientIDSecret string
+	// 数据收集接口的防滥用配置
+	TrackRatePerMinute  int64    // 每个IP每分钟补充的令牌数
+	TrackRateBurst      int64    // 令牌桶容量，即允许的突发事件数
+	TrackAllowedPaths   []string // 允许上报的路径模式，默认为博客前端的页面，设为/**时不限制路径
+	TrackEventTypes     []string // 允许上报的事件类型
+	TrackSiteKey        string   // 站点密钥，配置后上报时必须携带
+	TrackAllowedOrigins []string // 允许上报的来源站点，为空时不检查
+
+	TrackPropertiesMaxKeys  int64 // 事件属性最多的键数
+	TrackPropertiesMaxBytes int64 // 事件属性序列化为JSON后的最大字节数
 }
 
 var AppConfig *Config
@@ -42,6 +83,44 @@ func LoadConfig() {
 		R2BucketName:      getEnv("R2_BUCKET_NAME", ""),
 		R2Endpoint:        getEnv("R2_ENDPOINT", ""),
 		R2PublicURL:       getEnv("R2_PUBLIC_URL", ""),
+		LocalStoragePath:  getEnv("LOCAL_STORAGE_PATH", "./uploads"),
+	}
+
+	AppConfig.StoragePublicURL = getEnv("STORAGE_PUBLIC_URL", "http://localhost:"+AppConfig.Port)
+	AppConfig.StorageSigningKey = getEnv("STORAGE_SIGNING_KEY", AppConfig.JWTSecret)
+	AppConfig.MaxUploadSize = getEnvInt64("UPLOAD_MAX_SIZE", 5*1024*1024)
+	AppConfig.PrivateURLExpiry = getEnvInt64("PRIVATE_URL_EXPIRY_MINUTES", 60)
+	AppConfig.ImageBaseURL = getEnv("IMAGE_BASE_URL", AppConfig.StoragePublicURL)
+	AppConfig.MaxVideoUploadSize = getEnvInt64("UPLOAD_MAX_VIDEO_SIZE", 100*1024*1024)
+	AppConfig.TusUploadExpiry = getEnvInt64("TUS_UPLOAD_EXPIRY_HOURS", 24)
+	AppConfig.OrphanGraceHours = getEnvInt64("ORPHAN_GRACE_HOURS", 7*24)
+	AppConfig.StorageQuotaContributor = getEnvInt64("STORAGE_QUOTA_CONTRIBUTOR", 500*1024*1024)
+	AppConfig.StorageQuotaAdmin = getEnvInt64("STORAGE_QUOTA_ADMIN", 0)
+	AppConfig.GeoIPDatabasePath = getEnv("GEOIP_DB_PATH", "")
+	AppConfig.GeoIPLanguage = getEnv("GEOIP_LANGUAGE", "zh-CN")
+	AppConfig.GeoIPCacheSize = getEnvInt64("GEOIP_CACHE_SIZE", 10000)
+	AppConfig.BotIPRangesFile = getEnv("BOT_IP_RANGES_FILE", "")
+	AppConfig.BotRateLimit = getEnvInt64("BOT_RATE_PER_MINUTE", 60)
+	AppConfig.BotSessionPageViews = getEnvInt64("BOT_SESSION_PAGE_VIEWS", 30)
+	AppConfig.IPStorageMode = getEnv("IP_STORAGE_MODE", "none")
+	AppConfig.ClientIDSecret = getEnv("CLIENT_ID_SECRET", AppConfig.JWTSecret)
+	AppConfig.TrackRatePerMinute = getEnvInt64("TRACK_RATE_PER_MINUTE", 120)
+	AppConfig.TrackRateBurst = getEnvInt64("TRACK_RATE_BURST", 60)
+	AppConfig.TrackAllowedPaths = getEnvList("TRACK_ALLOWED_PATHS", "/,/articles/**,/about,/tags/**,/authors/**")
+	AppConfig.TrackEventTypes = getEnvList("TRACK_EVENT_TYPES", "page_view,article_click")
+	AppConfig.TrackSiteKey = getEnv("TRACK_SITE_KEY", "")
+	AppConfig.TrackAllowedOrigins = getEnvList("TRACK_ALLOWED_ORIGINS", "")
+	AppConfig.TrackPropertiesMaxKeys = getEnvInt64("TRACK_PROPERTIES_MAX_KEYS", 10)
+	AppConfig.TrackPropertiesMaxBytes = getEnvInt64("TRACK_PROPERTIES_MAX_BYTES", 1024)
+
+	// 未指定存储驱动时，配置了R2则使用R2，否则使用本地磁盘
+	AppConfig.StorageDriver = getEnv("STORAGE_DRIVER", "")
+	if AppConfig.StorageDriver == "" {
+		if AppConfig.R2AccessKeyID != "" {
+			AppConfig.StorageDriver = "s3"
+		} else {
+			AppConfig.StorageDriver = "local"
+		}
 	}
 
 	// 验证是否成功加载生产环境配置
@@ -59,4 +138,25 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// getEnvList 读取逗号分隔的列表，忽略空项
+func getEnvList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return parsed
+		}
+		log.Printf("环境变量%s格式错误，使用默认值%d", key, defaultValue)
+	}
+	return defaultValue
+}
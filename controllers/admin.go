@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"blog-server/models"
+	"blog-server/utils"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// ListUsers 获取用户列表（管理员）
+func ListUsers(c *gin.Context) {
+	var users []models.User
+	if err := models.DB.Order("id ASC").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取用户列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  users,
+		"total": len(users),
+	})
+}
+
+// UpdateUserRole 修改用户角色（管理员）
+func UpdateUserRole(c *gin.Context) {
+	id := c.Param("id")
+
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if !models.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的角色",
+		})
+		return
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "用户不存在",
+		})
+		return
+	}
+
+	// 防止移除最后一个管理员
+	if user.IsAdmin() && req.Role != models.RoleAdmin {
+		var adminCount int64
+		models.DB.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&adminCount)
+		if adminCount <= 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "至少需要保留一个管理员",
+			})
+			return
+		}
+	}
+
+	before := user
+	user.Role = req.Role
+	if err := models.DB.Model(&user).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "角色修改失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditUserRoleChange, "user", user.ID, before, user)
+
+	c.JSON(http.StatusOK, user)
+}
+
+type UpdateStorageQuotaRequest struct {
+	Quota *int64 `json:"quota" binding:"omitempty,min=0"` // 配额（字节），0表示不限，null恢复为角色默认值
+}
+
+// UpdateUserStorageQuota 调整用户的存储配额（管理员）
+func UpdateUserStorageQuota(c *gin.Context) {
+	var req UpdateStorageQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "用户不存在",
+		})
+		return
+	}
+
+	before := gin.H{"storage_quota": user.StorageQuota}
+	if err := models.DB.Model(&user).Update("storage_quota", req.Quota).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "修改配额失败",
+		})
+		return
+	}
+	user.StorageQuota = req.Quota
+
+	utils.RecordAudit(c, models.AuditUserQuotaChange, "user", user.ID, before, gin.H{"storage_quota": user.StorageQuota})
+
+	var mediaCount int64
+	models.DB.Model(&models.Media{}).Where("owner_id = ?", user.ID).Count(&mediaCount)
+
+	c.JSON(http.StatusOK, storageUsageResponse(&user, mediaCount))
+}
+
+// GetAuditLogs 查询审计日志（管理员）
+func GetAuditLogs(c *gin.Context) {
+	query, err := buildAuditLogQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 分页参数
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	offset := (page - 1) * limit
+
+	var total int64
+	query.Count(&total)
+
+	var logs []models.AuditLog
+	if err := query.Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "查询审计日志失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": logs,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// ExportAuditLogs 导出审计日志（管理员），支持csv和json格式
+func ExportAuditLogs(c *gin.Context) {
+	query, err := buildAuditLogQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "不支持的导出格式，请使用csv或json",
+		})
+		return
+	}
+
+	// 导出上限，防止一次性加载过多数据
+	var logs []models.AuditLog
+	if err := query.Order("created_at ASC").Limit(10000).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "查询审计日志失败: " + err.Error(),
+		})
+		return
+	}
+
+	fileName := fmt.Sprintf("audit_logs_%s.%s", time.Now().Format("20060102150405"), format)
+	c.Header("Content-Disposition", "attachment; filename="+fileName)
+
+	if format == "json" {
+		c.JSON(http.StatusOK, logs)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"id", "created_at", "actor_id", "actor_name", "action", "target_type", "target_id", "ip", "before", "after"})
+	for _, entry := range logs {
+		actorID := ""
+		if entry.ActorID != nil {
+			actorID = strconv.FormatUint(uint64(*entry.ActorID), 10)
+		}
+		writer.Write([]string{
+			strconv.FormatUint(uint64(entry.ID), 10),
+			entry.CreatedAt.Format(time.RFC3339),
+			actorID,
+			entry.ActorName,
+			entry.Action,
+			entry.TargetType,
+			entry.TargetID,
+			entry.IP,
+			derefString(entry.Before),
+			derefString(entry.After),
+		})
+	}
+	writer.Flush()
+}
+
+// buildAuditLogQuery 根据查询参数构建审计日志查询
+func buildAuditLogQuery(c *gin.Context) (*gorm.DB, error) {
+	query := models.DB.Model(&models.AuditLog{})
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID := c.Query("target_id"); targetID != "" {
+		query = query.Where("target_id = ?", targetID)
+	}
+
+	// 日期范围过滤
+	if startDate := c.Query("start_date"); startDate != "" {
+		if _, err := time.Parse("2006-01-02", startDate); err != nil {
+			return nil, errors.New("日期格式错误，请使用YYYY-MM-DD格式")
+		}
+		query = query.Where("created_at >= ?", startDate+" 00:00:00")
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		if _, err := time.Parse("2006-01-02", endDate); err != nil {
+			return nil, errors.New("日期格式错误，请使用YYYY-MM-DD格式")
+		}
+		query = query.Where("created_at <= ?", endDate+" 23:59:59")
+	}
+
+	return query, nil
+}
+
+// derefString 安全地解引用字符串指针
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -3,13 +3,17 @@ package controllers
 import (
 	"blog-server/models"
 	"blog-server/utils"
-	"crypto/md5"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
 )
 
 type TrackRequest struct {
@@ -17,6 +21,11 @@ type TrackRequest struct {
 	Referer   string `json:"referer"`
 	EventType string `json:"event_type" binding:"required"` // page_view, article_click, etc.
 	ArticleID *uint  `json:"article_id,omitempty"`
+	// 跟踪脚本生成并保存在浏览器中的随机ID（8到64位字母、数字、_或-），用于留存分析，格式不符时忽略
+	ClientID string `json:"client_id,omitempty"`
+
+	// 自定义属性，如复制代码块时的{"language": "go"}
+	Properties map[string]interface{} `json:"properties,omitempty"`
 }
 
 type AnalyticsResponse struct {
@@ -27,75 +36,351 @@ type AnalyticsResponse struct {
 	TopArticles    map[string]int64 `json:"top_articles"`
 }
 
-// Track 数据收集接口
-func Track(c *gin.Context) {
-	var req TrackRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "请求参数错误: " + err.Error(),
-		})
-		return
-	}
+// maxTrackBatchEvents 批量收集接口单次最多接收的事件数
+const maxTrackBatchEvents = 50
 
-	// 获取客户端信息
+// maxTrackBatchBody 批量收集接口的请求体大小上限（字节）
+const maxTrackBatchBody = 64 * 1024
+
+// TrackBatchResult 批量收集中单个事件的处理结果
+type TrackBatchResult struct {
+	Index    int    `json:"index"`
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// trackingClient 同一请求中所有事件共享的客户端信息
+type trackingClient struct {
+	ipAddress     string // 按IP_STORAGE_MODE处理后要保存的IP
+	visitorID     string
+	sessionID     string
+	userAgentHash string
+	uaInfo        utils.UserAgentInfo
+	location      utils.GeoLocation
+	isBot         bool
+	botReason     string
+}
+
+// resolveTrackingClient 解析客户端信息，原始IP和User-Agent只在这里使用，不会被保存
+// events为本次请求的事件数，用于按频率识别机器人
+func resolveTrackingClient(c *gin.Context, events int) (*trackingClient, error) {
 	ipAddress := utils.GetRealClientIP(c)
 	userAgent := c.GetHeader("User-Agent")
 	userAgentHash := utils.HashUserAgent(userAgent)
+	uaInfo := utils.ParseUserAgent(userAgent)
+
+	// 识别爬虫、监控和脚本请求
+	ctx := c.Request.Context()
+	isBot, botReason := utils.ClassifyBot(ctx, ipAddress, userAgent, uaInfo, events)
+
+	// 用每日更换的盐生成访客ID，之后的统计不再使用原始IP
+	visitorID, err := utils.VisitorID(ctx, time.Now().Format("2006-01-02"), ipAddress, userAgentHash)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, err := utils.SessionID(ctx, visitorID)
+	if err != nil {
+		return nil, err
+	}
 
-	// 生成会话ID（基于IP和User-Agent的简单哈希）
-	sessionData := fmt.Sprintf("%s_%s_%s", ipAddress, userAgent, time.Now().Format("2006-01-02"))
-	sessionID := fmt.Sprintf("%x", md5.Sum([]byte(sessionData)))
+	return &trackingClient{
+		ipAddress:     utils.StoredIPAddress(ipAddress),
+		visitorID:     visitorID,
+		sessionID:     sessionID,
+		userAgentHash: userAgentHash,
+		uaInfo:        uaInfo,
+		location:      utils.LookupGeo(ipAddress),
+		isBot:         isBot,
+		botReason:     botReason,
+	}, nil
+}
 
-	// 构建追踪数据
-	trackingData := utils.TrackingData{
+// trackingData 构建要存储的追踪数据
+// properties为校验后序列化的事件属性
+func (client *trackingClient) trackingData(req TrackRequest, properties json.RawMessage) utils.TrackingData {
+	return utils.TrackingData{
 		Timestamp: time.Now(),
 		Path:      req.Path,
-		IPAddress: ipAddress,
-		UserAgent: userAgentHash,
+		IPAddress: client.ipAddress,
+		VisitorID: client.visitorID,
+		ClientID:  utils.ClientID(req.ClientID),
+		UserAgent: client.userAgentHash,
 		Referer:   req.Referer,
 		EventType: req.EventType,
 		ArticleID: req.ArticleID,
-		SessionID: sessionID,
+		SessionID: client.sessionID,
+		Country:   client.location.Country,
+		City:      client.location.City,
+
+		Browser:        client.uaInfo.Browser,
+		BrowserVersion: client.uaInfo.BrowserVersion,
+		OS:             client.uaInfo.OS,
+		DeviceType:     client.uaInfo.DeviceType,
+
+		IsBot:     client.isBot,
+		BotReason: client.botReason,
+
+		Properties: properties,
 	}
+}
 
-	// 存储到Redis
-	ctx := c.Request.Context()
-	if err := utils.StoreTrackingData(ctx, trackingData); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "数据存储失败: " + err.Error(),
+// Track 数据收集接口
+func Track(c *gin.Context) {
+	if !checkTrackingSource(c, 1) {
+		return
+	}
+
+	var req TrackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RecordTrackingRejections(c.Request.Context(), utils.TrackRejectInvalid, 1)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
 		})
 		return
 	}
 
-	// 更新在线用户数量
-	if err := utils.UpdateOnlineUsers(ctx, ipAddress); err != nil {
-		// 这个错误不影响主要功能，只记录日志
-		fmt.Printf("更新在线用户数量失败: %v\n", err)
+	if !checkTrackingRate(c, 1) {
+		return
 	}
 
-	// 更新页面访问统计
-	if err := utils.UpdatePageViewStats(ctx, req.Path, req.EventType, req.ArticleID); err != nil {
-		fmt.Printf("更新页面访问统计失败: %v\n", err)
+	if err := utils.ValidateTrackingEvent(req.EventType, req.Path, req.ArticleID); err != nil {
+		utils.RecordTrackingRejections(c.Request.Context(), utils.TrackRejectReason(err), 1)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	properties, err := utils.ValidateTrackingProperties(req.Properties)
+	if err != nil {
+		utils.RecordTrackingRejections(c.Request.Context(), utils.TrackRejectReason(err), 1)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
 	}
 
-	// 更新独立访客统计
-	if err := utils.UpdateUniqueVisitors(ctx, ipAddress); err != nil {
-		fmt.Printf("更新独立访客统计失败: %v\n", err)
+	client, err := resolveTrackingClient(c, 1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "数据存储失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 存储到Redis并更新实时统计（机器人只计入单独的统计）
+	trackingData := client.trackingData(req, properties)
+	if err := utils.StoreTrackingBatch(c.Request.Context(), []utils.TrackingData{trackingData}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "数据存储失败: " + err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "数据收集成功",
 		"data": gin.H{
 			"timestamp":  trackingData.Timestamp,
-			"session_id": sessionID,
+			"session_id": trackingData.SessionID,
 		},
 	})
 }
 
+// TrackBatch 批量数据收集接口
+// 请求体为事件数组，支持navigator.sendBeacon发送的text/plain请求，返回每个事件的处理结果
+func TrackBatch(c *gin.Context) {
+	if !checkTrackingSource(c, 1) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxTrackBatchBody+1))
+	if err != nil {
+		utils.RecordTrackingRejections(ctx, utils.TrackRejectInvalid, 1)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "读取请求失败: " + err.Error(),
+		})
+		return
+	}
+	if len(body) > maxTrackBatchBody {
+		utils.RecordTrackingRejections(ctx, utils.TrackRejectRequestLimit, 1)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("请求体超过%d字节", maxTrackBatchBody),
+		})
+		return
+	}
+
+	var reqs []TrackRequest
+	if err := json.Unmarshal(body, &reqs); err != nil {
+		utils.RecordTrackingRejections(ctx, utils.TrackRejectInvalid, 1)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误，需要事件数组: " + err.Error(),
+		})
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > maxTrackBatchEvents {
+		utils.RecordTrackingRejections(ctx, utils.TrackRejectRequestLimit, max(len(reqs), 1))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("事件数量必须在1到%d之间", maxTrackBatchEvents),
+		})
+		return
+	}
+
+	if !checkTrackingRate(c, len(reqs)) {
+		return
+	}
+
+	client, err := resolveTrackingClient(c, len(reqs))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "数据存储失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 逐个校验事件，不合法的事件单独拒绝，不影响其他事件
+	results := make([]TrackBatchResult, len(reqs))
+	rejections := make(map[string]int)
+	var batch []utils.TrackingData
+	for i, req := range reqs {
+		results[i].Index = i
+		if err := binding.Validator.ValidateStruct(&req); err != nil {
+			results[i].Error = "请求参数错误: " + err.Error()
+			rejections[utils.TrackRejectInvalid]++
+			continue
+		}
+		if err := utils.ValidateTrackingEvent(req.EventType, req.Path, req.ArticleID); err != nil {
+			results[i].Error = err.Error()
+			rejections[utils.TrackRejectReason(err)]++
+			continue
+		}
+		properties, err := utils.ValidateTrackingProperties(req.Properties)
+		if err != nil {
+			results[i].Error = err.Error()
+			rejections[utils.TrackRejectReason(err)]++
+			continue
+		}
+		results[i].Accepted = true
+		batch = append(batch, client.trackingData(req, properties))
+	}
+	for reason, count := range rejections {
+		utils.RecordTrackingRejections(ctx, reason, count)
+	}
+
+	if err := utils.StoreTrackingBatch(ctx, batch); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "数据存储失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "数据收集成功",
+		"accepted":   len(batch),
+		"rejected":   len(reqs) - len(batch),
+		"session_id": client.sessionID,
+		"results":    results,
+	})
+}
+
+// checkTrackingSource 校验站点密钥和来源，不通过时写入403响应
+func checkTrackingSource(c *gin.Context, events int) bool {
+	if err := utils.VerifyTrackingSource(c); err != nil {
+		utils.RecordTrackingRejections(c.Request.Context(), utils.TrackRejectReason(err), events)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// checkTrackingRate 按IP限制上报频率，超出时写入429响应
+func checkTrackingRate(c *gin.Context, events int) bool {
+	allowed, wait := utils.AllowTrackingEvents(c.Request.Context(), utils.GetRealClientIP(c), events)
+	if allowed {
+		return true
+	}
+
+	utils.RecordTrackingRejections(c.Request.Context(), utils.TrackRejectRateLimited, events)
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": "上报过于频繁，请稍后再试",
+	})
+	return false
+}
+
+// GetTrackingRejections 获取数据收集接口被拒绝的事件数（管理员）
+// 按日期和原因统计，days为查询的天数（含今天）
+func GetTrackingRejections(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if days < 1 || days > 30 {
+		days = 7
+	}
+
+	type DailyRejections struct {
+		Date    string           `json:"date"`
+		Total   int64            `json:"total"`
+		Reasons map[string]int64 `json:"reasons"`
+	}
+
+	ctx := c.Request.Context()
+	totals := make(map[string]int64)
+	var data []DailyRejections
+	for i := 0; i < days; i++ {
+		date := time.Now().AddDate(0, 0, -i).Format("2006-01-02")
+		reasons, err := utils.GetTrackingRejections(ctx, date)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "查询拒绝统计失败: " + err.Error(),
+			})
+			return
+		}
+
+		day := DailyRejections{Date: date, Reasons: reasons}
+		for reason, count := range reasons {
+			day.Total += count
+			totals[reason] += count
+		}
+		data = append(data, day)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   data,
+		"totals": totals,
+		"days":   days,
+	})
+}
+
+// includeBots 统计是否包含机器人，默认排除，通过include_bots=true开启
+func includeBots(c *gin.Context) bool {
+	return c.Query("include_bots") == "true"
+}
+
+// botFilter 默认排除机器人产生的追踪事件
+func botFilter(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if includeBots(c) {
+			return db
+		}
+		return db.Where("is_bot = ?", false)
+	}
+}
+
 // GetRealTimeStats 获取实时统计数据
 func GetRealTimeStats(c *gin.Context) {
-	ctx := c.Request.Context()
+	response, err := realtimeSnapshot(c.Request.Context(), includeBots(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取统计数据失败: " + err.Error(),
+		})
+		return
+	}
 
+	c.JSON(http.StatusOK, response)
+}
+
+// realtimeSnapshot 从Redis读取在线用户和今日统计，withBots为true时加上机器人的数据
+func realtimeSnapshot(ctx context.Context, withBots bool) (AnalyticsResponse, error) {
 	// 获取在线用户数量
 	onlineUsers, err := utils.GetOnlineUsersCount(ctx)
 	if err != nil {
@@ -105,10 +390,7 @@ func GetRealTimeStats(c *gin.Context) {
 	// 获取今日统计数据
 	todayStats, err := utils.GetTodayStats(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取统计数据失败: " + err.Error(),
-		})
-		return
+		return AnalyticsResponse{}, err
 	}
 
 	// 解析统计数据
@@ -140,6 +422,19 @@ func GetRealTimeStats(c *gin.Context) {
 		}
 	}
 
+	// include_bots=true时加上机器人的在线数和访问量
+	if withBots {
+		if onlineBots, err := utils.GetOnlineBotsCount(ctx); err == nil {
+			onlineUsers += onlineBots
+		}
+		if botStats, err := utils.GetTodayBotStats(ctx); err == nil {
+			botVisitors, _ := strconv.ParseInt(botStats["unique_visitors"], 10, 64)
+			botPageViews, _ := strconv.ParseInt(botStats["page_views"], 10, 64)
+			todayVisitors += botVisitors
+			todayPageViews += botPageViews
+		}
+	}
+
 	response := AnalyticsResponse{
 		OnlineUsers:    onlineUsers,
 		TodayVisitors:  todayVisitors,
@@ -148,7 +443,7 @@ func GetRealTimeStats(c *gin.Context) {
 		TopArticles:    topArticles,
 	}
 
-	c.JSON(http.StatusOK, response)
+	return response, nil
 }
 
 // GetDailyStats 获取指定日期的统计数据
@@ -177,6 +472,11 @@ func GetDailyStats(c *gin.Context) {
 		return
 	}
 
+	if includeBots(c) {
+		dailyStats.PageViews += dailyStats.BotPageViews
+		dailyStats.UniqueVisitors += dailyStats.BotVisitors
+	}
+
 	c.JSON(http.StatusOK, dailyStats)
 }
 
@@ -211,6 +511,13 @@ func GetStatsRange(c *gin.Context) {
 		return
 	}
 
+	if includeBots(c) {
+		for i := range statsRange {
+			statsRange[i].PageViews += statsRange[i].BotPageViews
+			statsRange[i].UniqueVisitors += statsRange[i].BotVisitors
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":       statsRange,
 		"start_date": startDate,
@@ -273,9 +580,10 @@ func GetTrackingEvents(c *gin.Context) {
 	path := c.Query("path")
 	eventType := c.Query("event_type")
 	ipAddress := c.Query("ip_address")
+	visitorID := c.Query("visitor_id")
 
 	// 构建查询
-	query := models.DB.Model(&models.TrackingEvent{})
+	query := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c))
 	
 	// 日期过滤
 	startDate := dateStr + " 00:00:00"
@@ -292,6 +600,9 @@ func GetTrackingEvents(c *gin.Context) {
 	if ipAddress != "" {
 		query = query.Where("ip_address = ?", ipAddress)
 	}
+	if visitorID != "" {
+		query = query.Where("visitor_id = ?", visitorID)
+	}
 
 	// 获取总数
 	var total int64
@@ -322,11 +633,13 @@ func GetTrackingEvents(c *gin.Context) {
 			"path":       path,
 			"event_type": eventType,
 			"ip_address": ipAddress,
+			"visitor_id": visitorID,
 		},
 	})
 }
 
-// GetIPStats 获取IP访问统计
+// GetIPStats 获取访客访问统计
+// 按访客ID分组，IP只在IP_STORAGE_MODE=truncate时以网段形式返回
 func GetIPStats(c *gin.Context) {
 	dateStr := c.Query("date")
 	if dateStr == "" {
@@ -341,39 +654,40 @@ func GetIPStats(c *gin.Context) {
 	startDate := dateStr + " 00:00:00"
 	endDate := dateStr + " 23:59:59"
 
-	// 统计IP访问次数
-	type IPStat struct {
+	// 统计访客访问次数
+	type VisitorStat struct {
+		VisitorID string `json:"visitor_id"`
 		IPAddress string `json:"ip_address"`
 		Count     int64  `json:"count"`
 		LastVisit string `json:"last_visit"`
 	}
 
-	var ipStats []IPStat
-	if err := models.DB.Model(&models.TrackingEvent{}).
-		Select("ip_address, COUNT(*) as count, MAX(timestamp) as last_visit").
+	var visitorStats []VisitorStat
+	if err := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
+		Select("visitor_id, MAX(ip_address) as ip_address, COUNT(*) as count, MAX(timestamp) as last_visit").
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
-		Group("ip_address").
+		Group("visitor_id").
 		Order("count DESC").
 		Limit(limit).
-		Scan(&ipStats).Error; err != nil {
+		Scan(&visitorStats).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "查询IP统计失败: " + err.Error(),
+			"error": "查询访客统计失败: " + err.Error(),
 		})
 		return
 	}
 
-	// 获取总IP数量
-	var totalIPs int64
-	models.DB.Model(&models.TrackingEvent{}).
-		Select("DISTINCT ip_address").
+	// 获取总访客数量
+	var totalVisitors int64
+	models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
+		Select("DISTINCT visitor_id").
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
-		Count(&totalIPs)
+		Count(&totalVisitors)
 
 	c.JSON(http.StatusOK, gin.H{
-		"date":      dateStr,
-		"data":      ipStats,
-		"total_ips": totalIPs,
-		"limit":     limit,
+		"date":           dateStr,
+		"data":           visitorStats,
+		"total_visitors": totalVisitors,
+		"limit":          limit,
 	})
 }
 
@@ -399,7 +713,7 @@ func GetUserAgentStats(c *gin.Context) {
 	}
 
 	var uaStats []UserAgentStat
-	if err := models.DB.Model(&models.TrackingEvent{}).
+	if err := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
 		Select("user_agent, COUNT(*) as count, MAX(timestamp) as last_seen").
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
 		Group("user_agent").
@@ -440,7 +754,7 @@ func GetRefererStats(c *gin.Context) {
 	}
 
 	var refererStats []RefererStat
-	if err := models.DB.Model(&models.TrackingEvent{}).
+	if err := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
 		Select("referer, COUNT(*) as count").
 		Where("timestamp BETWEEN ? AND ? AND referer != ''", startDate, endDate).
 		Group("referer").
@@ -476,20 +790,20 @@ func GetSessionStats(c *gin.Context) {
 		EventCount   int64  `json:"event_count"`
 		FirstVisit   string `json:"first_visit"`
 		LastVisit    string `json:"last_visit"`
-		IPAddress    string `json:"ip_address"`
+		VisitorID    string `json:"visitor_id"`
 		Duration     int64  `json:"duration_seconds"`
 	}
 
 	var sessionStats []SessionStat
-	if err := models.DB.Model(&models.TrackingEvent{}).
+	if err := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
 		Select(`session_id, 
 				COUNT(*) as event_count, 
 				MIN(timestamp) as first_visit, 
 				MAX(timestamp) as last_visit, 
-				ip_address,
+				visitor_id,
 				EXTRACT(EPOCH FROM (MAX(timestamp) - MIN(timestamp))) as duration_seconds`).
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
-		Group("session_id, ip_address").
+		Group("session_id, visitor_id").
 		Order("event_count DESC").
 		Limit(100).
 		Scan(&sessionStats).Error; err != nil {
@@ -501,7 +815,7 @@ func GetSessionStats(c *gin.Context) {
 
 	// 总会话数
 	var totalSessions int64
-	models.DB.Model(&models.TrackingEvent{}).
+	models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
 		Select("DISTINCT session_id").
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
 		Count(&totalSessions)
@@ -529,7 +843,7 @@ func GetEventTypeStats(c *gin.Context) {
 	}
 
 	var eventStats []EventTypeStat
-	if err := models.DB.Model(&models.TrackingEvent{}).
+	if err := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
 		Select("event_type, COUNT(*) as count").
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
 		Group("event_type").
@@ -563,7 +877,7 @@ func GetHourlyStats(c *gin.Context) {
 	}
 
 	var hourlyStats []HourlyStat
-	if err := models.DB.Model(&models.TrackingEvent{}).
+	if err := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
 		Select("EXTRACT(HOUR FROM timestamp) as hour, COUNT(*) as count").
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
 		Group("EXTRACT(HOUR FROM timestamp)").
@@ -595,29 +909,70 @@ func GetHourlyStats(c *gin.Context) {
 	})
 }
 
+// PathAnalysis 页面的访问量、独立访客、平均停留时间和跳出率
+type PathAnalysis struct {
+	Path            string  `json:"path"`
+	TotalViews      int64   `json:"total_views"`
+	UniqueVisitors  int64   `json:"unique_visitors"`
+	AverageStayTime float64 `json:"average_stay_time"` // 秒
+	BounceRate      float64 `json:"bounce_rate"`       // 0到1之间
+	Entries         int64   `json:"entries"`
+	Bounces         int64   `json:"bounces"`
+}
+
 // GetPathAnalysis 获取路径详细分析
+// 已转存的日期直接读取页面热力图中的每日汇总，当天或include_bots=true时从追踪事件实时计算
 func GetPathAnalysis(c *gin.Context) {
 	dateStr := c.Query("date")
 	if dateStr == "" {
 		dateStr = time.Now().Format("2006-01-02")
 	}
 
-	startDate := dateStr + " 00:00:00"
-	endDate := dateStr + " 23:59:59"
+	date, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "日期格式错误，请使用YYYY-MM-DD格式",
+		})
+		return
+	}
 
-	type PathAnalysis struct {
-		Path            string  `json:"path"`
-		TotalViews      int64   `json:"total_views"`
-		UniqueVisitors  int64   `json:"unique_visitors"`
-		AverageStayTime float64 `json:"average_stay_time"`
-		BounceRate      float64 `json:"bounce_rate"`
+	if dateStr < time.Now().Format("2006-01-02") && !includeBots(c) {
+		var heatmaps []models.PageHeatmap
+		if err := models.DB.Where("date = ?", date).Order("views DESC").Find(&heatmaps).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "查询路径分析失败: " + err.Error(),
+			})
+			return
+		}
+		if len(heatmaps) > 0 {
+			pathAnalysis := make([]PathAnalysis, 0, len(heatmaps))
+			for _, heatmap := range heatmaps {
+				pathAnalysis = append(pathAnalysis, PathAnalysis{
+					Path:            heatmap.Path,
+					TotalViews:      heatmap.Views,
+					UniqueVisitors:  heatmap.UniqueVisitors,
+					AverageStayTime: heatmap.AverageStayTime,
+					BounceRate:      heatmap.BounceRate,
+					Entries:         heatmap.Entries,
+					Bounces:         heatmap.Bounces,
+				})
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"date": dateStr,
+				"data": pathAnalysis,
+			})
+			return
+		}
 	}
 
+	startDate := dateStr + " 00:00:00"
+	endDate := dateStr + " 23:59:59"
+
 	var pathAnalysis []PathAnalysis
-	if err := models.DB.Model(&models.TrackingEvent{}).
+	if err := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
 		Select(`path, 
 				COUNT(*) as total_views,
-				COUNT(DISTINCT ip_address) as unique_visitors`).
+				COUNT(DISTINCT visitor_id) as unique_visitors`).
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
 		Group("path").
 		Order("total_views DESC").
@@ -628,6 +983,25 @@ func GetPathAnalysis(c *gin.Context) {
 		return
 	}
 
+	engagement, err := models.QueryPathEngagement(models.DB, startDate, endDate, includeBots(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "计算跳出率和停留时间失败: " + err.Error(),
+		})
+		return
+	}
+	engagementByPath := make(map[string]models.PathEngagement, len(engagement))
+	for _, e := range engagement {
+		engagementByPath[e.Path] = e
+	}
+	for i := range pathAnalysis {
+		e := engagementByPath[pathAnalysis[i].Path]
+		pathAnalysis[i].AverageStayTime = e.AverageStayTime
+		pathAnalysis[i].BounceRate = e.BounceRate()
+		pathAnalysis[i].Entries = e.Entries
+		pathAnalysis[i].Bounces = e.Bounces
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"date": dateStr,
 		"data": pathAnalysis,
@@ -645,17 +1019,17 @@ func GetAdvancedStats(c *gin.Context) {
 	endDate := dateStr + " 23:59:59"
 
 	// 基础统计
-	var totalEvents, uniqueIPs, uniqueSessions int64
-	models.DB.Model(&models.TrackingEvent{}).
+	var totalEvents, uniqueVisitors, uniqueSessions int64
+	models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
 		Count(&totalEvents)
 	
-	models.DB.Model(&models.TrackingEvent{}).
-		Select("DISTINCT ip_address").
+	models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
+		Select("DISTINCT visitor_id").
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
-		Count(&uniqueIPs)
+		Count(&uniqueVisitors)
 	
-	models.DB.Model(&models.TrackingEvent{}).
+	models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
 		Select("DISTINCT session_id").
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
 		Count(&uniqueSessions)
@@ -668,7 +1042,7 @@ func GetAdvancedStats(c *gin.Context) {
 
 	// 平均会话时长
 	var avgSessionDuration float64
-	models.DB.Model(&models.TrackingEvent{}).
+	models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
 		Select("AVG(EXTRACT(EPOCH FROM (MAX(timestamp) - MIN(timestamp))))").
 		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
 		Group("session_id").
@@ -677,9 +1051,214 @@ func GetAdvancedStats(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"date":                  dateStr,
 		"total_events":          totalEvents,
-		"unique_visitors":       uniqueIPs,
+		"unique_visitors":       uniqueVisitors,
 		"unique_sessions":       uniqueSessions,
 		"avg_session_duration":  avgSessionDuration,
 		"avg_events_per_session": float64(totalEvents) / float64(uniqueSessions),
 	})
-}
\ No newline at end of file
+}
+
+// GetGeoStats 获取地理位置统计（按国家和城市）
+// 可通过country参数只查看某个国家的城市分布
+func GetGeoStats(c *gin.Context) {
+	dateStr := c.Query("date")
+	if dateStr == "" {
+		dateStr = time.Now().Format("2006-01-02")
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	country := c.Query("country")
+
+	startDate := dateStr + " 00:00:00"
+	endDate := dateStr + " 23:59:59"
+
+	type CountryStat struct {
+		Country  string `json:"country"`
+		Count    int64  `json:"count"`
+		Visitors int64  `json:"visitors"`
+	}
+
+	type CityStat struct {
+		Country  string `json:"country"`
+		City     string `json:"city"`
+		Count    int64  `json:"count"`
+		Visitors int64  `json:"visitors"`
+	}
+
+	var countryStats []CountryStat
+	if err := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
+		Select("country, COUNT(*) as count, COUNT(DISTINCT visitor_id) as visitors").
+		Where("timestamp BETWEEN ? AND ? AND country <> ''", startDate, endDate).
+		Group("country").
+		Order("count DESC").
+		Limit(limit).
+		Scan(&countryStats).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "查询国家统计失败: " + err.Error(),
+		})
+		return
+	}
+
+	cityQuery := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
+		Select("country, city, COUNT(*) as count, COUNT(DISTINCT visitor_id) as visitors").
+		Where("timestamp BETWEEN ? AND ? AND city <> ''", startDate, endDate)
+	if country != "" {
+		cityQuery = cityQuery.Where("country = ?", country)
+	}
+
+	var cityStats []CityStat
+	if err := cityQuery.
+		Group("country, city").
+		Order("count DESC").
+		Limit(limit).
+		Scan(&cityStats).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "查询城市统计失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 无法解析地理位置的事件数（内网IP、数据库中没有的IP或未启用GeoIP）
+	var unknown int64
+	models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
+		Where("timestamp BETWEEN ? AND ? AND (country = '' OR country IS NULL)", startDate, endDate).
+		Count(&unknown)
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":      dateStr,
+		"countries": countryStats,
+		"cities":    cityStats,
+		"unknown":   unknown,
+		"limit":     limit,
+	})
+}
+
+// DimensionStat 按某个维度分组的访问统计
+type DimensionStat struct {
+	Value    string `json:"value"`
+	Count    int64  `json:"count"`
+	Visitors int64  `json:"visitors"`
+}
+
+// queryDimensionStats 按指定列统计某天的事件数和独立访客数，空值归为unknown
+func queryDimensionStats(c *gin.Context, column, dateStr string, limit int) ([]DimensionStat, error) {
+	startDate := dateStr + " 00:00:00"
+	endDate := dateStr + " 23:59:59"
+
+	var stats []DimensionStat
+	err := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
+		Select("COALESCE(NULLIF("+column+", ''), 'unknown') as value, COUNT(*) as count, COUNT(DISTINCT visitor_id) as visitors").
+		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
+		Group("value").
+		Order("count DESC").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// respondDimensionStats 处理日期和数量参数并返回某个维度的统计
+func respondDimensionStats(c *gin.Context, column, name string) {
+	dateStr := c.Query("date")
+	if dateStr == "" {
+		dateStr = time.Now().Format("2006-01-02")
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	stats, err := queryDimensionStats(c, column, dateStr, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "查询" + name + "统计失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":  dateStr,
+		"data":  stats,
+		"limit": limit,
+	})
+}
+
+// GetBrowserStats 获取浏览器统计
+// versions=true时按浏览器和主版本号分组
+func GetBrowserStats(c *gin.Context) {
+	if c.Query("versions") == "true" {
+		respondDimensionStats(c, "TRIM(browser || ' ' || browser_version)", "浏览器版本")
+		return
+	}
+	respondDimensionStats(c, "browser", "浏览器")
+}
+
+// GetOSStats 获取操作系统统计
+func GetOSStats(c *gin.Context) {
+	respondDimensionStats(c, "os", "操作系统")
+}
+
+// GetDeviceStats 获取设备类型统计（desktop、mobile、tablet、bot）
+func GetDeviceStats(c *gin.Context) {
+	respondDimensionStats(c, "device_type", "设备类型")
+}
+
+// GetEventPropertyStats 获取事件属性统计
+// 指定key时按该属性的值分组，否则列出出现过的属性名；可用event_type筛选事件类型
+func GetEventPropertyStats(c *gin.Context) {
+	dateStr := c.Query("date")
+	if dateStr == "" {
+		dateStr = time.Now().Format("2006-01-02")
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	key := c.Query("key")
+	if key != "" && !utils.PropertyKeyPattern.MatchString(key) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "属性名格式错误",
+		})
+		return
+	}
+	eventType := c.Query("event_type")
+
+	startDate := dateStr + " 00:00:00"
+	endDate := dateStr + " 23:59:59"
+
+	query := models.DB.Model(&models.TrackingEvent{}).Scopes(botFilter(c)).
+		Where("timestamp BETWEEN ? AND ?", startDate, endDate).
+		Where("properties IS NOT NULL")
+	if eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+	if key != "" {
+		// 字符串、数字和布尔值统一按文本分组
+		query = query.Select("properties ->> ? as value, COUNT(*) as count, COUNT(DISTINCT visitor_id) as visitors", key).
+			Where("properties ->> ? IS NOT NULL", key)
+	} else {
+		query = query.Select("property.key as value, COUNT(*) as count, COUNT(DISTINCT visitor_id) as visitors").
+			Joins("CROSS JOIN LATERAL jsonb_object_keys(tracking_events.properties) AS property(key)")
+	}
+
+	var stats []DimensionStat
+	if err := query.Group("value").Order("count DESC").Limit(limit).Scan(&stats).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "查询事件属性统计失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":       dateStr,
+		"event_type": eventType,
+		"key":        key,
+		"data":       stats,
+		"limit":      limit,
+	})
+}
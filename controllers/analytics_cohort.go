@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"blog-server/models"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CohortPeriod 同一批访客在之后某个周期的回访情况
+type CohortPeriod struct {
+	Period     int     `json:"period"`
+	Visitors   int64   `json:"visitors"`
+	Percentage float64 `json:"percentage"` // 回访访客占该批访客的百分比
+}
+
+// CohortRow 按首次出现日期分组的一批访客
+// Retention中还没有结束的周期为null
+type CohortRow struct {
+	Cohort    string          `json:"cohort"`
+	Visitors  int64           `json:"visitors"`
+	Retention []*CohortPeriod `json:"retention"`
+}
+
+// cohortPeriods 分组周期的天数及默认、最大的回访周期数
+var cohortPeriods = map[string]struct {
+	days           int
+	defaultPeriods int
+	maxPeriods     int
+}{
+	"day":  {days: 1, defaultPeriods: 7, maxPeriods: 30},
+	"week": {days: 7, defaultPeriods: 8, maxPeriods: 26},
+}
+
+// cohortPeriodStart 日期所在周期的第一天，周从周一开始，与PostgreSQL的date_trunc一致
+func cohortPeriodStart(date time.Time, period string) time.Time {
+	if period == "week" {
+		return date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+	}
+	return date
+}
+
+// GetCohortStats 访客留存分析
+// 按客户端ID首次出现的日或周把访客分组，统计每组在之后各周期中再次访问的比例
+// 只统计上报了client_id的访客，当天的数据在次日转存后才会计入
+func GetCohortStats(c *gin.Context) {
+	period := c.DefaultQuery("period", "day")
+	periodConfig, ok := cohortPeriods[period]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "period只支持day或week",
+		})
+		return
+	}
+
+	periods, _ := strconv.Atoi(c.Query("periods"))
+	if periods <= 0 {
+		periods = periodConfig.defaultPeriods
+	}
+	periods = min(periods, periodConfig.maxPeriods)
+
+	// 追踪事件按天转存，最近完整的数据是昨天
+	lastDay, _ := time.Parse("2006-01-02", time.Now().AddDate(0, 0, -1).Format("2006-01-02"))
+
+	end, err := time.Parse("2006-01-02", c.DefaultQuery("end", lastDay.Format("2006-01-02")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "日期格式错误，请使用YYYY-MM-DD格式",
+		})
+		return
+	}
+	defaultStart := end.AddDate(0, 0, -(2*periodConfig.days*periods - 1))
+	start, err := time.Parse("2006-01-02", c.DefaultQuery("start", defaultStart.Format("2006-01-02")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "日期格式错误，请使用YYYY-MM-DD格式",
+		})
+		return
+	}
+	start = cohortPeriodStart(start, period)
+	end = cohortPeriodStart(end, period).AddDate(0, 0, periodConfig.days-1)
+	if start.After(end) || end.Sub(start) > 366*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "日期范围无效，最多查询一年",
+		})
+		return
+	}
+	until := end.AddDate(0, 0, periodConfig.days*periods+1)
+
+	params := map[string]interface{}{
+		"unit":  period,
+		"start": start.Format("2006-01-02"),
+		"end":   end.Format("2006-01-02"),
+		"until": until.Format("2006-01-02"),
+	}
+
+	var cohorts []struct {
+		Cohort   time.Time
+		Visitors int64
+	}
+	if err := models.DB.Raw(`SELECT DATE(date_trunc(@unit, first_seen)) AS cohort, COUNT(*) AS visitors
+		FROM visitor_first_seens
+		WHERE first_seen BETWEEN @start AND @end
+		GROUP BY 1 ORDER BY 1`, params).Scan(&cohorts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "查询访客分组失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 每组访客在各周期的活跃人数，只扫描查询范围内的追踪事件
+	var activity []struct {
+		Cohort   time.Time
+		Active   time.Time
+		Visitors int64
+	}
+	if err := models.DB.Raw(`SELECT DATE(date_trunc(@unit, f.first_seen)) AS cohort,
+			DATE(date_trunc(@unit, e.timestamp)) AS active,
+			COUNT(DISTINCT f.client_id) AS visitors
+		FROM visitor_first_seens f
+		JOIN tracking_events e ON e.client_id = f.client_id
+		WHERE f.first_seen BETWEEN @start AND @end
+			AND e.timestamp >= @start AND e.timestamp < @until
+			AND e.deleted_at IS NULL AND e.is_bot = false
+		GROUP BY 1, 2`, params).Scan(&activity).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "查询访客留存失败: " + err.Error(),
+		})
+		return
+	}
+
+	activeVisitors := make(map[string]int64, len(activity))
+	for _, a := range activity {
+		activeVisitors[a.Cohort.Format("2006-01-02")+"|"+a.Active.Format("2006-01-02")] = a.Visitors
+	}
+
+	rows := make([]CohortRow, 0, len(cohorts))
+	for _, cohort := range cohorts {
+		cohortKey := cohort.Cohort.Format("2006-01-02")
+		row := CohortRow{
+			Cohort:    cohortKey,
+			Visitors:  cohort.Visitors,
+			Retention: make([]*CohortPeriod, 0, periods+1),
+		}
+		for k := 0; k <= periods; k++ {
+			periodStart := cohort.Cohort.AddDate(0, 0, k*periodConfig.days)
+			periodEnd := periodStart.AddDate(0, 0, periodConfig.days-1)
+
+			// 周期还没有结束
+			if periodEnd.After(lastDay) {
+				row.Retention = append(row.Retention, nil)
+				continue
+			}
+
+			visitors := activeVisitors[cohortKey+"|"+periodStart.Format("2006-01-02")]
+			if k == 0 {
+				visitors = cohort.Visitors
+			}
+			percentage := 0.0
+			if cohort.Visitors > 0 {
+				percentage = math.Round(float64(visitors)*10000/float64(cohort.Visitors)) / 100
+			}
+			row.Retention = append(row.Retention, &CohortPeriod{
+				Period:     k,
+				Visitors:   visitors,
+				Percentage: percentage,
+			})
+		}
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"period":  period,
+		"periods": periods,
+		"start":   start.Format("2006-01-02"),
+		"end":     end.Format("2006-01-02"),
+		"data":    rows,
+	})
+}
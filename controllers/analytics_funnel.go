@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"blog-server/models"
+	"blog-server/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxFunnelRangeDays 漏斗分析单次查询的最大天数
+const maxFunnelRangeDays = 90
+
+type FunnelStepRequest struct {
+	Name      string `json:"name" binding:"max=100"`
+	Path      string `json:"path" binding:"max=512"`
+	EventType string `json:"event_type" binding:"max=32"`
+}
+
+type FunnelRequest struct {
+	Name          string              `json:"name" binding:"required,max=100"`
+	Description   string              `json:"description" binding:"max=500"`
+	WindowMinutes int                 `json:"window_minutes" binding:"omitempty,min=1,max=10080"` // 默认30分钟，最长7天
+	Steps         []FunnelStepRequest `json:"steps" binding:"required,min=2,max=10,dive"`
+}
+
+// orderedFunnelSteps 按步骤顺序预加载
+func orderedFunnelSteps(db *gorm.DB) *gorm.DB {
+	return db.Order("step_order ASC")
+}
+
+// bindFunnelRequest 解析并校验漏斗定义，失败时写入400响应
+func bindFunnelRequest(c *gin.Context) (*FunnelRequest, bool) {
+	var req FunnelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return nil, false
+	}
+	for _, step := range req.Steps {
+		if step.Path == "" && step.EventType == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "每个步骤至少需要指定path或event_type",
+			})
+			return nil, false
+		}
+	}
+	if req.WindowMinutes == 0 {
+		req.WindowMinutes = models.DefaultFunnelWindowMinutes
+	}
+	return &req, true
+}
+
+// funnelSteps 将请求中的步骤转换为模型，顺序即步骤顺序
+func funnelSteps(funnelID uint, reqs []FunnelStepRequest) []models.FunnelStep {
+	steps := make([]models.FunnelStep, 0, len(reqs))
+	for i, req := range reqs {
+		steps = append(steps, models.FunnelStep{
+			FunnelID:  funnelID,
+			StepOrder: i,
+			Name:      req.Name,
+			Path:      req.Path,
+			EventType: req.EventType,
+		})
+	}
+	return steps
+}
+
+// ListFunnels 获取保存的漏斗列表
+func ListFunnels(c *gin.Context) {
+	var funnels []models.Funnel
+	if err := models.DB.Preload("Steps", orderedFunnelSteps).Order("id ASC").Find(&funnels).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取漏斗列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": funnels,
+	})
+}
+
+// CreateFunnel 保存漏斗定义
+func CreateFunnel(c *gin.Context) {
+	req, ok := bindFunnelRequest(c)
+	if !ok {
+		return
+	}
+
+	funnel := models.Funnel{
+		Name:          req.Name,
+		Description:   req.Description,
+		WindowMinutes: req.WindowMinutes,
+	}
+	if userID, exists := c.Get("user_id"); exists {
+		if id, ok := userID.(uint); ok {
+			funnel.CreatedByID = &id
+		}
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Steps").Create(&funnel).Error; err != nil {
+			return err
+		}
+		funnel.Steps = funnelSteps(funnel.ID, req.Steps)
+		return tx.Create(&funnel.Steps).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "漏斗创建失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, funnel)
+}
+
+// UpdateFunnel 修改漏斗定义，步骤整体替换
+func UpdateFunnel(c *gin.Context) {
+	req, ok := bindFunnelRequest(c)
+	if !ok {
+		return
+	}
+
+	var funnel models.Funnel
+	if err := models.DB.First(&funnel, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "漏斗不存在",
+		})
+		return
+	}
+
+	funnel.Name = req.Name
+	funnel.Description = req.Description
+	funnel.WindowMinutes = req.WindowMinutes
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Steps").Save(&funnel).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("funnel_id = ?", funnel.ID).Delete(&models.FunnelStep{}).Error; err != nil {
+			return err
+		}
+		funnel.Steps = funnelSteps(funnel.ID, req.Steps)
+		return tx.Create(&funnel.Steps).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "漏斗更新失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, funnel)
+}
+
+// DeleteFunnel 删除漏斗定义及其步骤
+func DeleteFunnel(c *gin.Context) {
+	var funnel models.Funnel
+	if err := models.DB.First(&funnel, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "漏斗不存在",
+		})
+		return
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("funnel_id = ?", funnel.ID).Delete(&models.FunnelStep{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&funnel).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "漏斗删除失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "漏斗删除成功",
+	})
+}
+
+// GetFunnelReport 按会话计算漏斗各步骤的转化和流失
+// start、end为YYYY-MM-DD格式的日期（包含end当天），默认最近7天
+func GetFunnelReport(c *gin.Context) {
+	var funnel models.Funnel
+	if err := models.DB.Preload("Steps", orderedFunnelSteps).First(&funnel, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "漏斗不存在",
+		})
+		return
+	}
+
+	endStr := c.DefaultQuery("end", time.Now().Format("2006-01-02"))
+	end, err := time.ParseInLocation("2006-01-02", endStr, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "日期格式错误，请使用YYYY-MM-DD格式",
+		})
+		return
+	}
+	startStr := c.DefaultQuery("start", end.AddDate(0, 0, -6).Format("2006-01-02"))
+	start, err := time.ParseInLocation("2006-01-02", startStr, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "日期格式错误，请使用YYYY-MM-DD格式",
+		})
+		return
+	}
+	if start.After(end) || end.Sub(start) >= maxFunnelRangeDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "日期范围无效，最多查询90天",
+		})
+		return
+	}
+
+	steps, err := utils.AnalyzeFunnel(funnel, start, end.AddDate(0, 0, 1), includeBots(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "漏斗分析失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"funnel": funnel,
+		"start":  startStr,
+		"end":    endStr,
+		"steps":  steps,
+	})
+}
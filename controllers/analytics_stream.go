@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"blog-server/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamStatsInterval 统计数据的推送间隔，数据没有变化时跳过
+	streamStatsInterval = 5 * time.Second
+	// streamHeartbeatInterval 心跳间隔，防止代理断开空闲连接，也便于客户端发现连接已失效
+	streamHeartbeatInterval = 15 * time.Second
+	// streamRetryMillis 连接断开后浏览器重连前的等待时间
+	streamRetryMillis = 3000
+)
+
+// StreamAnalytics 通过Server-Sent Events推送实时数据
+// 事件类型：stats为在线用户和今日统计，event为新收集到的事件，heartbeat为心跳
+// 新事件通过Redis发布订阅分发，任意实例收集到的事件都会推送给所有实例上的连接
+func StreamAnalytics(c *gin.Context) {
+	ctx := c.Request.Context()
+	withBots := includeBots(c)
+
+	pubsub := utils.SubscribeTrackingEvents(ctx)
+	defer pubsub.Close()
+
+	// 确认订阅成功后再开始推送
+	if _, err := pubsub.Receive(ctx); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "订阅实时事件失败: " + err.Error(),
+		})
+		return
+	}
+	messages := pubsub.Channel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭Nginx的响应缓冲
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetryMillis)
+
+	var lastStats []byte
+	sendStats := func() {
+		snapshot, err := realtimeSnapshot(ctx, withBots)
+		if err != nil {
+			return
+		}
+		data, err := json.Marshal(snapshot)
+		if err != nil || bytes.Equal(data, lastStats) {
+			return
+		}
+		lastStats = data
+		c.SSEvent("stats", json.RawMessage(data))
+	}
+
+	sendStats()
+	c.Writer.Flush()
+
+	statsTicker := time.NewTicker(streamStatsInterval)
+	defer statsTicker.Stop()
+	heartbeatTicker := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeatTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var events []utils.LiveTrackingEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &events); err != nil {
+				continue
+			}
+			for _, event := range events {
+				if event.IsBot && !withBots {
+					continue
+				}
+				c.SSEvent("event", event)
+			}
+		case <-statsTicker.C:
+			sendStats()
+		case now := <-heartbeatTicker.C:
+			c.SSEvent("heartbeat", gin.H{"time": now.Unix()})
+		}
+		c.Writer.Flush()
+	}
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"blog-server/models"
+	"blog-server/utils"
 	"net/http"
 	"strconv"
 	"strings"
@@ -39,6 +40,9 @@ func CreateArticle(c *gin.Context) {
 		req.Status = "draft"
 	}
 
+	// 私有文件的签名URL会过期，保存前还原为媒体记录的URL
+	req.Content = unsignPrivateMediaURLs(req.Content)
+
 	// 使用事务确保数据一致性
 	tx := models.DB.Begin()
 	defer func() {
@@ -82,6 +86,8 @@ func CreateArticle(c *gin.Context) {
 	// 预加载用户信息和内容
 	models.DB.Preload("User").Preload("Content").First(&article, article.ID)
 
+	utils.RecordAudit(c, models.AuditArticleCreate, "article", article.ID, nil, article)
+
 	c.JSON(http.StatusCreated, article)
 }
 
@@ -172,9 +178,29 @@ func GetArticle(c *gin.Context) {
 		return
 	}
 
+	// 附带正文图片的响应式版本，前端可直接生成srcset
+	// 作者和管理员查看时，正文中的私有文件替换为有时效的签名URL
+	if article.Content != nil {
+		content := article.Content.Content
+		var signed map[string]string
+		if canViewPrivateMedia(c, &article) {
+			article.Content.Content, signed = signPrivateMediaURLs(content)
+		}
+		article.Images = responsiveImagesForContent(content, signed)
+	}
+
 	c.JSON(http.StatusOK, article)
 }
 
+// canViewPrivateMedia 当前登录用户是否可以查看文章中的私有文件（作者或管理员）
+func canViewPrivateMedia(c *gin.Context, article *models.Article) bool {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return false
+	}
+	return article.UserID == userID.(uint) || isAdminUser(userID.(uint))
+}
+
 // UpdateArticle 更新文章
 func UpdateArticle(c *gin.Context) {
 	id := c.Param("id")
@@ -195,7 +221,7 @@ func UpdateArticle(c *gin.Context) {
 	}
 
 	var article models.Article
-	if err := models.DB.First(&article, id).Error; err != nil {
+	if err := models.DB.Preload("Content").First(&article, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "文章不存在",
 		})
@@ -210,6 +236,17 @@ func UpdateArticle(c *gin.Context) {
 		return
 	}
 
+	// 记录修改前快照
+	before := article
+	if article.Content != nil {
+		contentCopy := *article.Content
+		before.Content = &contentCopy
+	}
+	article.Content = nil
+
+	// 私有文件的签名URL会过期，保存前还原为媒体记录的URL
+	req.Content = unsignPrivateMediaURLs(req.Content)
+
 	// 使用事务确保数据一致性
 	tx := models.DB.Begin()
 	defer func() {
@@ -265,6 +302,8 @@ func UpdateArticle(c *gin.Context) {
 	// 预加载用户信息和内容
 	models.DB.Preload("User").Preload("Content").First(&article, article.ID)
 
+	utils.RecordAudit(c, models.AuditArticleUpdate, "article", article.ID, before, article)
+
 	c.JSON(http.StatusOK, article)
 }
 
@@ -280,7 +319,7 @@ func DeleteArticle(c *gin.Context) {
 	}
 
 	var article models.Article
-	if err := models.DB.First(&article, id).Error; err != nil {
+	if err := models.DB.Preload("Content").First(&article, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "文章不存在",
 		})
@@ -303,6 +342,8 @@ func DeleteArticle(c *gin.Context) {
 		return
 	}
 
+	utils.RecordAudit(c, models.AuditArticleDelete, "article", article.ID, article, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "文章删除成功",
 	})
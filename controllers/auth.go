@@ -62,11 +62,20 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// 第一个注册的用户为管理员，其余为投稿者
+	role := models.RoleContributor
+	var userCount int64
+	models.DB.Model(&models.User{}).Count(&userCount)
+	if userCount == 0 {
+		role = models.RoleAdmin
+	}
+
 	// 创建用户
 	user := models.User{
 		Username: req.Username,
 		Email:    req.Email,
 		Password: hashedPassword,
+		Role:     role,
 	}
 
 	if err := models.DB.Create(&user).Error; err != nil {
@@ -76,6 +85,8 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	utils.RecordAudit(c, models.AuditUserCreate, "user", user.ID, nil, user)
+
 	// 生成JWT令牌
 	token, err := utils.GenerateToken(user.ID, user.Username)
 	if err != nil {
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"blog-server/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeTransformedImage 实时缩放、裁剪和转换图片格式
+// URL必须由GetImageTransformURL签名，避免被用来生成任意数量的版本；结果会缓存并长期有效
+func ServeTransformedImage(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if utils.IsPrivateKey(key) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "图片不存在",
+		})
+		return
+	}
+
+	opts, err := utils.ParseImageTransformOptions(c.Query("w"), c.Query("h"), c.Query("fit"), c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := utils.VerifyImageTransformSignature(key, opts, c.Query("s")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if utils.Storage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "存储服务不可用",
+		})
+		return
+	}
+
+	etag := utils.ImageTransformETag(key, opts)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	data, contentType, err := utils.TransformImage(key, opts)
+	if err != nil {
+		c.Header("Cache-Control", "no-store")
+		switch {
+		case errors.Is(err, utils.ErrObjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "图片不存在",
+			})
+		case errors.Is(err, utils.ErrImageNotProcessable):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "处理图片失败: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	c.Header("Content-Length", strconv.Itoa(len(data)))
+	c.Data(http.StatusOK, contentType, data)
+}
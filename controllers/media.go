@@ -0,0 +1,423 @@
+package controllers
+
+import (
+	"blog-server/config"
+	"blog-server/models"
+	"blog-server/utils"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListMedia 媒体库列表，普通用户只能看到自己上传的文件，管理员可查看全部
+// 支持q（文件名搜索）、mime_type、owner_id（仅管理员）过滤
+func ListMedia(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权",
+		})
+		return
+	}
+
+	query := models.DB.Model(&models.Media{}).Preload("Variants")
+	if isAdminUser(userID.(uint)) {
+		if ownerID := c.Query("owner_id"); ownerID != "" {
+			query = query.Where("owner_id = ?", ownerID)
+		}
+		query = query.Preload("Owner")
+	} else {
+		query = query.Where("owner_id = ?", userID)
+	}
+
+	if keyword := c.Query("q"); keyword != "" {
+		pattern := "%" + keyword + "%"
+		query = query.Where("original_name ILIKE ? OR key ILIKE ?", pattern, pattern)
+	}
+	if mimeType := c.Query("mime_type"); mimeType != "" {
+		query = query.Where("mime_type = ?", mimeType)
+	}
+
+	// 分页参数
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	var total int64
+	query.Count(&total)
+
+	var media []models.Media
+	if err := query.Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&media).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取媒体列表失败: " + err.Error(),
+		})
+		return
+	}
+	for i := range media {
+		utils.SignPrivateMedia(&media[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": media,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetStorageUsage 获取当前用户的存储用量和配额
+func GetStorageUsage(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权",
+		})
+		return
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "用户不存在",
+		})
+		return
+	}
+
+	var mediaCount int64
+	models.DB.Model(&models.Media{}).Where("owner_id = ?", user.ID).Count(&mediaCount)
+
+	c.JSON(http.StatusOK, storageUsageResponse(&user, mediaCount))
+}
+
+// storageUsageResponse 存储用量信息，quota为0表示不限
+func storageUsageResponse(user *models.User, mediaCount int64) gin.H {
+	quota := utils.StorageQuotaFor(user)
+	return gin.H{
+		"user_id":       user.ID,
+		"used":          user.StorageUsed,
+		"quota":         quota,
+		"unlimited":     quota <= 0,
+		"remaining":     utils.RemainingStorage(user),
+		"custom_quota":  user.StorageQuota != nil,
+		"media_count":   mediaCount,
+		"max_file_size": config.AppConfig.MaxUploadSize,
+	}
+}
+
+// GetMedia 获取单个媒体详情，包含各版本和srcset信息
+func GetMedia(c *gin.Context) {
+	media, ok := findManagedMedia(c)
+	if !ok {
+		return
+	}
+
+	models.DB.Where("blob_id = ?", media.BlobID).Find(&media.Variants)
+	utils.SignPrivateMedia(media)
+
+	c.JSON(http.StatusOK, gin.H{
+		"media":      media,
+		"responsive": media.Responsive(),
+	})
+}
+
+// ProcessMediaVariants 重新生成媒体的响应式版本（上传者或管理员）
+func ProcessMediaVariants(c *gin.Context) {
+	media, ok := findManagedMedia(c)
+	if !ok {
+		return
+	}
+
+	if utils.Storage == nil {
+		if err := utils.InitStorage(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "存储服务初始化失败: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	models.DB.Model(media).Update("status", models.MediaStatusPending)
+	utils.ProcessMediaAsync(media.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "已开始处理",
+	})
+}
+
+// GetResponsiveImages 根据图片URL获取响应式图片信息（无需认证）
+// 支持多个url参数，未登记到媒体库的URL和私有文件会被忽略
+func GetResponsiveImages(c *gin.Context) {
+	urls := c.QueryArray("url")
+	if len(urls) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少url参数",
+		})
+		return
+	}
+	if len(urls) > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "一次最多查询50个URL",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"images": responsiveImagesByURL(urls, nil),
+	})
+}
+
+// GetImageTransformURL 为媒体库图片生成带签名的实时处理URL
+// 参数w、h、fit（contain、cover、fill）、format（jpeg、png、webp）与/img接口一致
+func GetImageTransformURL(c *gin.Context) {
+	rawURL := c.Query("url")
+	if rawURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少url参数",
+		})
+		return
+	}
+
+	opts, err := utils.ParseImageTransformOptions(c.Query("w"), c.Query("h"), c.Query("fit"), c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 只为媒体库中的图片签名
+	var media models.Media
+	if err := models.DB.Where("url = ?", rawURL).First(&media).Error; err != nil {
+		key := utils.KeyFromPublicURL(rawURL)
+		if key == "" || models.DB.Where("key = ?", key).First(&media).Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "图片不存在",
+			})
+			return
+		}
+	}
+	if !strings.HasPrefix(media.MimeType, "image/") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "只能处理图片",
+		})
+		return
+	}
+	// 处理URL长期有效，私有文件不能通过它访问
+	if media.Private {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "私有文件不支持实时处理",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":      utils.SignImageTransformURL(media.Key, opts),
+		"media_id": media.ID,
+	})
+}
+
+// contentImagePattern 匹配Markdown图片和HTML img标签中的地址
+var contentImagePattern = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?[^)]*\)|<img[^>]+src=["']([^"']+)["']`)
+
+// contentURLPattern 匹配正文中出现的所有URL，私有文件可能以链接而非图片的形式引用
+var contentURLPattern = regexp.MustCompile(`https?://[^\s"'<>()\[\]]+`)
+
+// responsiveImagesForContent 查找正文中引用的媒体库图片
+// signed为私有文件原URL到签名URL的映射，为nil时不返回私有文件
+func responsiveImagesForContent(content string, signed map[string]string) map[string]models.ResponsiveImage {
+	var urls []string
+	for _, match := range contentImagePattern.FindAllStringSubmatch(content, -1) {
+		if match[1] != "" {
+			urls = append(urls, match[1])
+		} else if match[2] != "" {
+			urls = append(urls, match[2])
+		}
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+	return responsiveImagesByURL(urls, signed)
+}
+
+// responsiveImagesByURL 按URL查询已生成版本的媒体
+// 私有文件只在signed中有对应签名URL时返回，并以签名URL为键
+func responsiveImagesByURL(urls []string, signed map[string]string) map[string]models.ResponsiveImage {
+	query := models.DB.Preload("Variants").
+		Where("url IN ? AND status = ?", urls, models.MediaStatusReady)
+	if signed == nil {
+		query = query.Where("private = ?", false)
+	}
+
+	var media []models.Media
+	query.Find(&media)
+
+	images := make(map[string]models.ResponsiveImage, len(media))
+	for i := range media {
+		key := media[i].URL
+		if media[i].Private {
+			signedURL, ok := signed[key]
+			if !ok {
+				continue
+			}
+			utils.SignPrivateMedia(&media[i])
+			media[i].SignedURL = signedURL
+			key = signedURL
+		}
+		images[key] = media[i].Responsive()
+	}
+	return images
+}
+
+// signPrivateMediaURLs 将正文中引用的私有文件替换为有时效的签名URL，返回替换后的正文和URL映射
+func signPrivateMediaURLs(content string) (string, map[string]string) {
+	signed := map[string]string{}
+	urls := contentURLPattern.FindAllString(content, -1)
+	if len(urls) == 0 {
+		return content, signed
+	}
+
+	var media []models.Media
+	models.DB.Select("id", "key", "url").Where("url IN ? AND private = ?", urls, true).Find(&media)
+	for _, item := range media {
+		if _, ok := signed[item.URL]; ok {
+			continue
+		}
+		signedURL, err := utils.SignedDownloadURL(item.Key)
+		if err != nil {
+			continue
+		}
+		signed[item.URL] = signedURL
+		content = strings.ReplaceAll(content, item.URL, signedURL)
+	}
+	return content, signed
+}
+
+// unsignPrivateMediaURLs 将正文中私有文件的签名URL还原为媒体记录的URL
+// 作者编辑时拿到的是签名后的正文，保存时需要还原，否则链接会过期
+func unsignPrivateMediaURLs(content string) string {
+	keys := map[string][]string{}
+	for _, rawURL := range contentURLPattern.FindAllString(content, -1) {
+		if key := utils.PrivateKeyFromSignedURL(rawURL); key != "" {
+			keys[key] = append(keys[key], rawURL)
+		}
+	}
+	if len(keys) == 0 {
+		return content
+	}
+
+	keyList := make([]string, 0, len(keys))
+	for key := range keys {
+		keyList = append(keyList, key)
+	}
+
+	var media []models.Media
+	models.DB.Select("id", "key", "url").Where("key IN ? AND private = ?", keyList, true).Find(&media)
+	for _, item := range media {
+		for _, rawURL := range keys[item.Key] {
+			if rawURL != item.URL {
+				content = strings.ReplaceAll(content, rawURL, item.URL)
+			}
+		}
+	}
+	return content
+}
+
+// DeleteMedia 删除媒体文件及记录，仅上传者或管理员可删除
+func DeleteMedia(c *gin.Context) {
+	media, ok := findManagedMedia(c)
+	if !ok {
+		return
+	}
+
+	if utils.Storage == nil {
+		if err := utils.InitStorage(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "存储服务初始化失败: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	deleteMedia(c, media)
+}
+
+// findManagedMedia 根据路径参数查找媒体，并检查当前用户是否有权管理
+func findManagedMedia(c *gin.Context) (*models.Media, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权",
+		})
+		return nil, false
+	}
+
+	var media models.Media
+	if err := models.DB.First(&media, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "媒体不存在",
+		})
+		return nil, false
+	}
+
+	if media.OwnerID != userID.(uint) && !isAdminUser(userID.(uint)) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "无权操作此媒体",
+		})
+		return nil, false
+	}
+
+	return &media, true
+}
+
+// createMedia 登记上传成功的文件，相同内容的文件只保存一份
+func createMedia(c *gin.Context, key, originalName string, ownerID uint, meta utils.ImageMeta) (*models.Media, error) {
+	media, created, err := utils.CreateMedia(key, originalName, ownerID, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	if created {
+		utils.RecordAudit(c, models.AuditImageUpload, "media", media.ID, nil, media)
+	}
+	utils.SignPrivateMedia(media)
+	return media, nil
+}
+
+// deleteMedia 检查权限后删除存储对象和媒体记录
+func deleteMedia(c *gin.Context, media *models.Media) {
+	userID, _ := c.Get("user_id")
+	if media.OwnerID != userID.(uint) && !isAdminUser(userID.(uint)) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "无权删除此图片",
+		})
+		return
+	}
+
+	if err := utils.DeleteMedia(media); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "图片删除失败: " + err.Error(),
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditImageDelete, "media", media.ID, media, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "图片删除成功",
+	})
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"blog-server/models"
+	"blog-server/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PurgeOrphansRequest struct {
+	Confirm bool `json:"confirm"` // 必须为true，确认删除
+}
+
+// ListOrphanScans 孤立文件扫描报告列表（管理员）
+func ListOrphanScans(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	var total int64
+	models.DB.Model(&models.OrphanScan{}).Count(&total)
+
+	var scans []models.OrphanScan
+	if err := models.DB.Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&scans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取扫描报告失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": scans,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// CreateOrphanScan 立即执行一次孤立文件扫描（管理员），只生成报告不删除
+func CreateOrphanScan(c *gin.Context) {
+	if utils.Storage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "存储服务不可用",
+		})
+		return
+	}
+
+	scan, err := utils.ScanOrphans(models.OrphanScanManual)
+	if err != nil {
+		if errors.Is(err, utils.ErrOrphanScanRunning) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "扫描失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, scan)
+}
+
+// GetOrphanScan 获取扫描报告及孤立文件列表（管理员）
+func GetOrphanScan(c *gin.Context) {
+	var scan models.OrphanScan
+	if err := models.DB.Preload("Objects", func(db *gorm.DB) *gorm.DB {
+		return db.Order("last_modified ASC")
+	}).First(&scan, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "扫描报告不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, scan)
+}
+
+// PurgeOrphanScan 确认删除扫描报告中的孤立文件（管理员）
+func PurgeOrphanScan(c *gin.Context) {
+	var req PurgeOrphansRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !req.Confirm {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请传入{\"confirm\": true}确认删除",
+		})
+		return
+	}
+
+	if utils.Storage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "存储服务不可用",
+		})
+		return
+	}
+
+	var scan models.OrphanScan
+	if err := models.DB.First(&scan, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "扫描报告不存在",
+		})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	purged, err := utils.PurgeOrphanScan(&scan, userID.(uint))
+	if err != nil {
+		if errors.Is(err, utils.ErrOrphanScanRunning) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "清理失败: " + err.Error(),
+			"purged": purged,
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditOrphanPurge, "orphan_scan", scan.ID, nil, gin.H{
+		"purged": purged,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "清理完成",
+		"purged":  purged,
+	})
+}
@@ -2,9 +2,13 @@ package controllers
 
 import (
 	"blog-server/models"
+	"blog-server/utils"
+	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ProfileRequest struct {
@@ -22,11 +26,10 @@ type ProfileRequest struct {
 	Position string `json:"position"`
 }
 
-// GetPublicProfile 获取公共信息（无需认证）
+// GetPublicProfile 获取站点主人的公共信息（无需认证）
 func GetPublicProfile(c *gin.Context) {
 	var profile models.Profile
-	// 获取第一条记录，如果没有则创建默认值
-	if err := models.DB.First(&profile).Error; err != nil {
+	if err := models.PreloadProfileSections(models.DB).Where("is_site_owner = ?", true).First(&profile).Error; err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "暂无个人信息",
 		})
@@ -47,20 +50,22 @@ func UpdateProfile(c *gin.Context) {
 	}
 
 	// 检查认证
-	_, exists := c.Get("user_id")
+	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "未授权",
 		})
 		return
 	}
+	uid := userID.(uint)
 
 	var profile models.Profile
-	// 尝试获取现有记录
-	err := models.DB.First(&profile).Error
+	// 尝试获取当前用户的资料
+	err := models.DB.Where("user_id = ?", uid).First(&profile).Error
 	if err != nil {
 		// 如果不存在，创建新记录
 		profile = models.Profile{
+			UserID:   &uid,
 			Name:     req.Name,
 			Email:    req.Email,
 			Bio:      req.Bio,
@@ -75,13 +80,24 @@ func UpdateProfile(c *gin.Context) {
 			Position: req.Position,
 		}
 
+		// 还没有站点主人时，管理员创建的第一份资料作为站点主人资料
+		var ownerCount int64
+		models.DB.Model(&models.Profile{}).Where("is_site_owner = ?", true).Count(&ownerCount)
+		if ownerCount == 0 && isAdminUser(uid) {
+			profile.IsSiteOwner = true
+		}
+
 		if err := models.DB.Create(&profile).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "个人信息创建失败",
 			})
 			return
 		}
+
+		utils.RecordAudit(c, models.AuditProfileUpdate, "profile", profile.ID, nil, profile)
 	} else {
+		before := profile
+
 		// 更新现有记录
 		profile.Name = req.Name
 		profile.Email = req.Email
@@ -102,7 +118,192 @@ func UpdateProfile(c *gin.Context) {
 			})
 			return
 		}
+
+		utils.RecordAudit(c, models.AuditProfileUpdate, "profile", profile.ID, before, profile)
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// GetMyProfile 获取当前用户自己的资料（需要认证）
+func GetMyProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权",
+		})
+		return
+	}
+
+	var profile models.Profile
+	if err := models.PreloadProfileSections(models.DB).Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "尚未创建个人资料",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// GetAuthor 获取作者资料及其已发布文章（无需认证）
+func GetAuthor(c *gin.Context) {
+	username := c.Param("username")
+
+	var user models.User
+	if err := models.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "作者不存在",
+		})
+		return
+	}
+
+	var profile *models.Profile
+	var p models.Profile
+	if err := models.PreloadProfileSections(models.DB).Where("user_id = ?", user.ID).First(&p).Error; err == nil {
+		profile = &p
+	}
+
+	// 分页
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	offset := (page - 1) * limit
+
+	query := models.DB.Model(&models.Article{}).
+		Where("user_id = ? AND status = ?", user.ID, "published")
+
+	var total int64
+	query.Count(&total)
+
+	var articles []models.Article
+	if err := query.Preload("User").Offset(offset).Limit(limit).Order("created_at DESC").Find(&articles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取文章列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"author": gin.H{
+			"id":         user.ID,
+			"username":   user.Username,
+			"created_at": user.CreatedAt,
+		},
+		"profile":  profile,
+		"articles": articles,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+type SiteOwnerRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+// SetSiteOwner 指定站点主人资料（管理员）
+func SetSiteOwner(c *gin.Context) {
+	var req SiteOwnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	var profile models.Profile
+	if err := models.DB.Where("user_id = ?", req.UserID).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "该用户尚未创建个人资料",
+		})
+		return
+	}
+
+	var previous models.Profile
+	hasPrevious := models.DB.Where("is_site_owner = ?", true).First(&previous).Error == nil
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Profile{}).Where("is_site_owner = ?", true).Update("is_site_owner", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&profile).Update("is_site_owner", true).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "设置站点主人失败",
+		})
+		return
+	}
+
+	var before interface{}
+	if hasPrevious {
+		before = gin.H{"profile_id": previous.ID, "user_id": previous.UserID}
 	}
+	utils.RecordAudit(c, models.AuditSiteOwnerChange, "profile", profile.ID, before, gin.H{"profile_id": profile.ID, "user_id": profile.UserID})
 
 	c.JSON(http.StatusOK, profile)
-}
\ No newline at end of file
+}
+
+// isAdminUser 检查用户是否为管理员
+func isAdminUser(userID uint) bool {
+	var user models.User
+	if err := models.DB.First(&user, userID).Error; err != nil {
+		return false
+	}
+	return user.IsAdmin()
+}
+
+// ExportProfile 导出公共资料（无需认证）
+// format: jsonresume（JSON Resume）、vcard（vCard 4.0）、jsonld（schema.org Person）
+// 默认导出站点主人资料，可通过username参数指定作者
+func ExportProfile(c *gin.Context) {
+	query := models.PreloadProfileSections(models.DB)
+	if username := c.Query("username"); username != "" {
+		var user models.User
+		if err := models.DB.Where("username = ?", username).First(&user).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "作者不存在",
+			})
+			return
+		}
+		query = query.Where("user_id = ?", user.ID)
+	} else {
+		query = query.Where("is_site_owner = ?", true)
+	}
+
+	var profile models.Profile
+	if err := query.First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "暂无个人信息",
+		})
+		return
+	}
+
+	switch c.DefaultQuery("format", "jsonresume") {
+	case "jsonresume":
+		c.Header("Content-Disposition", "inline; filename="+utils.ProfileExportFileName(profile, "json"))
+		c.JSON(http.StatusOK, utils.BuildJSONResume(profile))
+	case "vcard":
+		c.Header("Content-Disposition", "attachment; filename="+utils.ProfileExportFileName(profile, "vcf"))
+		c.Data(http.StatusOK, "text/vcard; charset=utf-8", []byte(utils.BuildVCard(profile)))
+	case "jsonld":
+		data, err := json.Marshal(utils.BuildPersonJSONLD(profile))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "生成JSON-LD失败",
+			})
+			return
+		}
+		c.Data(http.StatusOK, "application/ld+json; charset=utf-8", data)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "不支持的导出格式，请使用jsonresume、vcard或jsonld",
+		})
+	}
+}
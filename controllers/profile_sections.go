@@ -0,0 +1,788 @@
+package controllers
+
+import (
+	"blog-server/models"
+	"blog-server/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SkillRequest struct {
+	Name      string `json:"name" binding:"required,max=100"`
+	Level     string `json:"level" binding:"omitempty,oneof=beginner intermediate advanced expert"`
+	Category  string `json:"category" binding:"max=100"`
+	SortOrder *int   `json:"sort_order"`
+}
+
+type ExperienceRequest struct {
+	Company     string `json:"company" binding:"required,max=200"`
+	Position    string `json:"position" binding:"required,max=200"`
+	Location    string `json:"location" binding:"max=200"`
+	URL         string `json:"url" binding:"omitempty,url"`
+	StartDate   string `json:"start_date" binding:"required,datetime=2006-01"`
+	EndDate     string `json:"end_date" binding:"omitempty,datetime=2006-01"`
+	Description string `json:"description" binding:"max=5000"`
+	SortOrder   *int   `json:"sort_order"`
+}
+
+type EducationRequest struct {
+	Institution string `json:"institution" binding:"required,max=200"`
+	Degree      string `json:"degree" binding:"max=100"`
+	Field       string `json:"field" binding:"max=200"`
+	StartDate   string `json:"start_date" binding:"omitempty,datetime=2006-01"`
+	EndDate     string `json:"end_date" binding:"omitempty,datetime=2006-01"`
+	Description string `json:"description" binding:"max=5000"`
+	SortOrder   *int   `json:"sort_order"`
+}
+
+type ProjectRequest struct {
+	Name         string `json:"name" binding:"required,max=200"`
+	Description  string `json:"description" binding:"max=5000"`
+	URL          string `json:"url" binding:"omitempty,url"`
+	RepoURL      string `json:"repo_url" binding:"omitempty,url"`
+	Technologies string `json:"technologies" binding:"max=500"`
+	StartDate    string `json:"start_date" binding:"omitempty,datetime=2006-01"`
+	EndDate      string `json:"end_date" binding:"omitempty,datetime=2006-01"`
+	SortOrder    *int   `json:"sort_order"`
+}
+
+type ReorderRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1"`
+}
+
+// ListSkills 获取自己的技能列表
+func ListSkills(c *gin.Context) {
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var skills []models.ProfileSkill
+	if err := models.DB.Where("profile_id = ?", profile.ID).
+		Order("sort_order ASC, id ASC").
+		Find(&skills).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取技能失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": skills,
+	})
+}
+
+// CreateSkill 添加技能
+func CreateSkill(c *gin.Context) {
+	var req SkillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	skill := models.ProfileSkill{
+		ProfileID: profile.ID,
+		Name:      req.Name,
+		Level:     req.Level,
+		Category:  req.Category,
+	}
+	if req.SortOrder != nil {
+		skill.SortOrder = *req.SortOrder
+	} else {
+		skill.SortOrder = nextSortOrder(&models.ProfileSkill{}, profile.ID)
+	}
+
+	if err := models.DB.Create(&skill).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "技能创建失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionCreate, "profile_skill", skill.ID, nil, skill)
+	c.JSON(http.StatusCreated, skill)
+}
+
+// UpdateSkill 修改技能
+func UpdateSkill(c *gin.Context) {
+	var req SkillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var skill models.ProfileSkill
+	if err := models.DB.Where("id = ? AND profile_id = ?", c.Param("id"), profile.ID).First(&skill).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "技能不存在",
+		})
+		return
+	}
+	before := skill
+
+	skill.Name = req.Name
+	skill.Level = req.Level
+	skill.Category = req.Category
+	if req.SortOrder != nil {
+		skill.SortOrder = *req.SortOrder
+	}
+
+	if err := models.DB.Save(&skill).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "技能更新失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionUpdate, "profile_skill", skill.ID, before, skill)
+	c.JSON(http.StatusOK, skill)
+}
+
+// DeleteSkill 删除技能
+func DeleteSkill(c *gin.Context) {
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var skill models.ProfileSkill
+	if err := models.DB.Where("id = ? AND profile_id = ?", c.Param("id"), profile.ID).First(&skill).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "技能不存在",
+		})
+		return
+	}
+
+	if err := models.DB.Delete(&skill).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "技能删除失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionDelete, "profile_skill", skill.ID, skill, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "技能删除成功",
+	})
+}
+
+// ReorderSkills 调整技能顺序，未列出的条目保持原有排序
+func ReorderSkills(c *gin.Context) {
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	if err := reorderProfileItems(&models.ProfileSkill{}, profile.ID, req.IDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "技能排序失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionReorder, "profile_skill", profile.ID, nil, gin.H{"ids": req.IDs})
+	ListSkills(c)
+}
+
+// ListExperiences 获取自己的工作经历
+func ListExperiences(c *gin.Context) {
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var experiences []models.ProfileExperience
+	if err := models.DB.Where("profile_id = ?", profile.ID).
+		Order("sort_order ASC, id ASC").
+		Find(&experiences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取工作经历失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": experiences,
+	})
+}
+
+// CreateExperience 添加工作经历
+func CreateExperience(c *gin.Context) {
+	var req ExperienceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if msg := checkDateRange(req.StartDate, req.EndDate); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	experience := models.ProfileExperience{
+		ProfileID:   profile.ID,
+		Company:     req.Company,
+		Position:    req.Position,
+		Location:    req.Location,
+		URL:         req.URL,
+		StartDate:   req.StartDate,
+		EndDate:     req.EndDate,
+		Description: req.Description,
+	}
+	if req.SortOrder != nil {
+		experience.SortOrder = *req.SortOrder
+	} else {
+		experience.SortOrder = nextSortOrder(&models.ProfileExperience{}, profile.ID)
+	}
+
+	if err := models.DB.Create(&experience).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "工作经历创建失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionCreate, "profile_experience", experience.ID, nil, experience)
+	c.JSON(http.StatusCreated, experience)
+}
+
+// UpdateExperience 修改工作经历
+func UpdateExperience(c *gin.Context) {
+	var req ExperienceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if msg := checkDateRange(req.StartDate, req.EndDate); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var experience models.ProfileExperience
+	if err := models.DB.Where("id = ? AND profile_id = ?", c.Param("id"), profile.ID).First(&experience).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "工作经历不存在",
+		})
+		return
+	}
+	before := experience
+
+	experience.Company = req.Company
+	experience.Position = req.Position
+	experience.Location = req.Location
+	experience.URL = req.URL
+	experience.StartDate = req.StartDate
+	experience.EndDate = req.EndDate
+	experience.Description = req.Description
+	if req.SortOrder != nil {
+		experience.SortOrder = *req.SortOrder
+	}
+
+	if err := models.DB.Save(&experience).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "工作经历更新失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionUpdate, "profile_experience", experience.ID, before, experience)
+	c.JSON(http.StatusOK, experience)
+}
+
+// DeleteExperience 删除工作经历
+func DeleteExperience(c *gin.Context) {
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var experience models.ProfileExperience
+	if err := models.DB.Where("id = ? AND profile_id = ?", c.Param("id"), profile.ID).First(&experience).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "工作经历不存在",
+		})
+		return
+	}
+
+	if err := models.DB.Delete(&experience).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "工作经历删除失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionDelete, "profile_experience", experience.ID, experience, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "工作经历删除成功",
+	})
+}
+
+// ReorderExperiences 调整工作经历顺序，未列出的条目保持原有排序
+func ReorderExperiences(c *gin.Context) {
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	if err := reorderProfileItems(&models.ProfileExperience{}, profile.ID, req.IDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "工作经历排序失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionReorder, "profile_experience", profile.ID, nil, gin.H{"ids": req.IDs})
+	ListExperiences(c)
+}
+
+// ListEducations 获取自己的教育经历
+func ListEducations(c *gin.Context) {
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var educations []models.ProfileEducation
+	if err := models.DB.Where("profile_id = ?", profile.ID).
+		Order("sort_order ASC, id ASC").
+		Find(&educations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取教育经历失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": educations,
+	})
+}
+
+// CreateEducation 添加教育经历
+func CreateEducation(c *gin.Context) {
+	var req EducationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if msg := checkDateRange(req.StartDate, req.EndDate); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	education := models.ProfileEducation{
+		ProfileID:   profile.ID,
+		Institution: req.Institution,
+		Degree:      req.Degree,
+		Field:       req.Field,
+		StartDate:   req.StartDate,
+		EndDate:     req.EndDate,
+		Description: req.Description,
+	}
+	if req.SortOrder != nil {
+		education.SortOrder = *req.SortOrder
+	} else {
+		education.SortOrder = nextSortOrder(&models.ProfileEducation{}, profile.ID)
+	}
+
+	if err := models.DB.Create(&education).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "教育经历创建失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionCreate, "profile_education", education.ID, nil, education)
+	c.JSON(http.StatusCreated, education)
+}
+
+// UpdateEducation 修改教育经历
+func UpdateEducation(c *gin.Context) {
+	var req EducationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if msg := checkDateRange(req.StartDate, req.EndDate); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var education models.ProfileEducation
+	if err := models.DB.Where("id = ? AND profile_id = ?", c.Param("id"), profile.ID).First(&education).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "教育经历不存在",
+		})
+		return
+	}
+	before := education
+
+	education.Institution = req.Institution
+	education.Degree = req.Degree
+	education.Field = req.Field
+	education.StartDate = req.StartDate
+	education.EndDate = req.EndDate
+	education.Description = req.Description
+	if req.SortOrder != nil {
+		education.SortOrder = *req.SortOrder
+	}
+
+	if err := models.DB.Save(&education).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "教育经历更新失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionUpdate, "profile_education", education.ID, before, education)
+	c.JSON(http.StatusOK, education)
+}
+
+// DeleteEducation 删除教育经历
+func DeleteEducation(c *gin.Context) {
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var education models.ProfileEducation
+	if err := models.DB.Where("id = ? AND profile_id = ?", c.Param("id"), profile.ID).First(&education).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "教育经历不存在",
+		})
+		return
+	}
+
+	if err := models.DB.Delete(&education).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "教育经历删除失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionDelete, "profile_education", education.ID, education, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "教育经历删除成功",
+	})
+}
+
+// ReorderEducations 调整教育经历顺序，未列出的条目保持原有排序
+func ReorderEducations(c *gin.Context) {
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	if err := reorderProfileItems(&models.ProfileEducation{}, profile.ID, req.IDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "教育经历排序失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionReorder, "profile_education", profile.ID, nil, gin.H{"ids": req.IDs})
+	ListEducations(c)
+}
+
+// ListProjects 获取自己的项目列表
+func ListProjects(c *gin.Context) {
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var projects []models.ProfileProject
+	if err := models.DB.Where("profile_id = ?", profile.ID).
+		Order("sort_order ASC, id ASC").
+		Find(&projects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取项目失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": projects,
+	})
+}
+
+// CreateProject 添加项目
+func CreateProject(c *gin.Context) {
+	var req ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if msg := checkDateRange(req.StartDate, req.EndDate); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	project := models.ProfileProject{
+		ProfileID:    profile.ID,
+		Name:         req.Name,
+		Description:  req.Description,
+		URL:          req.URL,
+		RepoURL:      req.RepoURL,
+		Technologies: req.Technologies,
+		StartDate:    req.StartDate,
+		EndDate:      req.EndDate,
+	}
+	if req.SortOrder != nil {
+		project.SortOrder = *req.SortOrder
+	} else {
+		project.SortOrder = nextSortOrder(&models.ProfileProject{}, profile.ID)
+	}
+
+	if err := models.DB.Create(&project).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "项目创建失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionCreate, "profile_project", project.ID, nil, project)
+	c.JSON(http.StatusCreated, project)
+}
+
+// UpdateProject 修改项目
+func UpdateProject(c *gin.Context) {
+	var req ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if msg := checkDateRange(req.StartDate, req.EndDate); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var project models.ProfileProject
+	if err := models.DB.Where("id = ? AND profile_id = ?", c.Param("id"), profile.ID).First(&project).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "项目不存在",
+		})
+		return
+	}
+	before := project
+
+	project.Name = req.Name
+	project.Description = req.Description
+	project.URL = req.URL
+	project.RepoURL = req.RepoURL
+	project.Technologies = req.Technologies
+	project.StartDate = req.StartDate
+	project.EndDate = req.EndDate
+	if req.SortOrder != nil {
+		project.SortOrder = *req.SortOrder
+	}
+
+	if err := models.DB.Save(&project).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "项目更新失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionUpdate, "profile_project", project.ID, before, project)
+	c.JSON(http.StatusOK, project)
+}
+
+// DeleteProject 删除项目
+func DeleteProject(c *gin.Context) {
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	var project models.ProfileProject
+	if err := models.DB.Where("id = ? AND profile_id = ?", c.Param("id"), profile.ID).First(&project).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "项目不存在",
+		})
+		return
+	}
+
+	if err := models.DB.Delete(&project).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "项目删除失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionDelete, "profile_project", project.ID, project, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "项目删除成功",
+	})
+}
+
+// ReorderProjects 调整项目顺序，未列出的条目保持原有排序
+func ReorderProjects(c *gin.Context) {
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	profile, ok := currentUserProfile(c)
+	if !ok {
+		return
+	}
+
+	if err := reorderProfileItems(&models.ProfileProject{}, profile.ID, req.IDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "项目排序失败",
+		})
+		return
+	}
+
+	utils.RecordAudit(c, models.AuditProfileSectionReorder, "profile_project", profile.ID, nil, gin.H{"ids": req.IDs})
+	ListProjects(c)
+}
+
+// nextSortOrder 未指定排序时追加到末尾
+func nextSortOrder(model interface{}, profileID uint) int {
+	var maxOrder *int
+	models.DB.Model(model).Where("profile_id = ?", profileID).Select("MAX(sort_order)").Scan(&maxOrder)
+	if maxOrder == nil {
+		return 0
+	}
+	return *maxOrder + 1
+}
+
+// reorderProfileItems 按给定ID顺序重新设置排序，只修改属于该资料的条目
+func reorderProfileItems(model interface{}, profileID uint, ids []uint) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			if err := tx.Model(model).
+				Where("id = ? AND profile_id = ?", id, profileID).
+				Update("sort_order", i).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// currentUserProfile 获取当前用户的资料，不存在时返回错误响应
+func currentUserProfile(c *gin.Context) (*models.Profile, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权",
+		})
+		return nil, false
+	}
+
+	var profile models.Profile
+	if err := models.DB.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "请先创建个人资料",
+		})
+		return nil, false
+	}
+
+	return &profile, true
+}
+
+// checkDateRange 检查结束日期不早于开始日期（格式均为YYYY-MM）
+func checkDateRange(start, end string) string {
+	if start != "" && end != "" && end < start {
+		return "结束日期不能早于开始日期"
+	}
+	return ""
+}
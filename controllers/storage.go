@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"blog-server/utils"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadStorageObject 接收预签名上传（仅local、memory存储驱动）
+func UploadStorageObject(c *gin.Context) {
+	backend, ok := utils.Storage.(utils.SignedUploadStorage)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "当前存储驱动不支持此操作",
+		})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	contentType := c.Query("content_type")
+	sizeStr := c.Query("size")
+
+	if err := backend.VerifyUploadSignature(key, contentType, sizeStr, c.Query("expires"), c.Query("signature")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "上传签名校验失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 与S3预签名一致，Content-Type和Content-Length必须与签名时声明的一致
+	if c.ContentType() != contentType {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Content-Type与签名不一致",
+		})
+		return
+	}
+
+	size, _ := strconv.ParseInt(sizeStr, 10, 64)
+	if c.Request.ContentLength != size {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Content-Length与签名不一致",
+		})
+		return
+	}
+
+	body := &utils.SizeLimitReader{R: c.Request.Body, Limit: size}
+	if err := backend.Put(key, body, size, contentType); err != nil {
+		if errors.Is(err, utils.ErrFileTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "文件超过声明的大小",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "文件上传失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// ServeStorageObject 提供文件下载（仅local、memory存储驱动）
+// 私有前缀下的文件需要带有效的下载签名
+func ServeStorageObject(c *gin.Context) {
+	backend, ok := utils.Storage.(utils.SignedUploadStorage)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "文件不存在",
+		})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	// 私有文件只能通过有时效的签名URL访问
+	cacheControl := "public, max-age=86400"
+	if utils.IsPrivateKey(key) {
+		if err := backend.VerifyDownloadSignature(key, c.Query("expires"), c.Query("signature")); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "下载签名校验失败: " + err.Error(),
+			})
+			return
+		}
+		cacheControl = "private, max-age=300"
+	}
+
+	reader, info, err := backend.Get(key)
+	if err != nil {
+		if errors.Is(err, utils.ErrObjectNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "文件不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "读取文件失败: " + err.Error(),
+		})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", info.ContentType)
+	c.Header("Cache-Control", cacheControl)
+
+	// 支持Seek时交给ServeContent处理Range和条件请求
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, info.Key, info.LastModified, seeker)
+		return
+	}
+
+	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, reader)
+}
@@ -0,0 +1,371 @@
+package controllers
+
+import (
+	"blog-server/models"
+	"blog-server/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tusContentType PATCH请求必须使用的Content-Type
+const tusContentType = "application/offset+octet-stream"
+
+// SetTusOptionsHeaders 设置tus协议的能力声明（OPTIONS请求）
+func SetTusOptionsHeaders(c *gin.Context) {
+	maxSize := utils.MaxUploadSizeFor("video/mp4")
+	if imageMax := utils.MaxUploadSizeFor("image/jpeg"); imageMax > maxSize {
+		maxSize = imageMax
+	}
+
+	c.Header("Tus-Resumable", utils.TusVersion)
+	c.Header("Tus-Version", utils.TusVersion)
+	c.Header("Tus-Extension", utils.TusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(maxSize, 10))
+}
+
+// CreateTusUpload 创建断点续传会话（tus creation扩展）
+// 通过Upload-Metadata传递filename和filetype（private为true时上传为私有文件），返回的Location用于后续上传分块
+func CreateTusUpload(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权",
+		})
+		return
+	}
+	if !checkTusResumable(c) {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少有效的Upload-Length",
+		})
+		return
+	}
+
+	metadata, err := utils.ParseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	// 兼容Uppy等客户端使用的name、type键
+	if metadata["filename"] == "" {
+		metadata["filename"] = metadata["name"]
+	}
+	if metadata["filetype"] == "" {
+		metadata["filetype"] = metadata["type"]
+	}
+	fileName := filepath.Base(metadata["filename"])
+	contentType := utils.NormalizeContentType(metadata["filetype"])
+	if fileName == "." || fileName == "/" || contentType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Upload-Metadata需要包含filename和filetype",
+		})
+		return
+	}
+
+	if err := utils.ValidateMediaDeclaration(fileName, contentType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	maxSize := utils.MaxUploadSizeFor(contentType)
+	if length > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("文件大小超过限制（最大%d字节）", maxSize),
+		})
+		return
+	}
+
+	if _, ok := checkUploadQuota(c, userID.(uint), length); !ok {
+		return
+	}
+
+	if utils.Storage == nil {
+		if err := utils.InitStorage(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "存储服务初始化失败: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	upload := models.TusUpload{
+		ID:          utils.NewTusUploadID(),
+		OwnerID:     userID.(uint),
+		Length:      length,
+		FileName:    fileName,
+		ContentType: contentType,
+		Metadata:    c.GetHeader("Upload-Metadata"),
+		Private:     metadata["private"] == "true",
+		ExpiresAt:   utils.TusExpiresAt(),
+	}
+	if err := models.DB.Create(&upload).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "创建上传失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.Header("Location", c.Request.URL.Path+"/"+upload.ID)
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// GetTusUploadOffset 查询已上传的字节数（HEAD），客户端据此从断点继续上传
+func GetTusUploadOffset(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	upload, ok := findTusUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.Metadata != "" {
+		c.Header("Upload-Metadata", upload.Metadata)
+	}
+	if upload.CompletedAt == nil {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	c.Status(http.StatusOK)
+}
+
+// GetTusUpload 查询上传会话的状态，完成后包含登记的媒体记录
+func GetTusUpload(c *gin.Context) {
+	upload, ok := findTusUpload(c)
+	if !ok {
+		return
+	}
+	if upload.MediaID != nil {
+		models.DB.First(&upload.Media, *upload.MediaID)
+	}
+	c.JSON(http.StatusOK, upload)
+}
+
+// PatchTusUpload 从Upload-Offset位置继续上传分块
+// 全部上传完成后合并分块、校验文件内容并登记到媒体库
+func PatchTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+
+	if c.ContentType() != tusContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "Content-Type必须为" + tusContentType,
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少有效的Upload-Offset",
+		})
+		return
+	}
+
+	upload, ok := findTusUpload(c)
+	if !ok {
+		return
+	}
+
+	unlock, ok := utils.LockTusUpload(upload)
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "该上传正在接收其他分块",
+		})
+		return
+	}
+	defer unlock()
+
+	if offset != upload.Offset {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": utils.ErrTusOffsetMismatch.Error(),
+		})
+		return
+	}
+
+	// 上一次合并失败时，客户端重试空的PATCH会再次合并
+	if upload.Offset < upload.Length {
+		if _, err := utils.WriteTusChunk(upload, c.Request.Body); err != nil {
+			switch {
+			case errors.Is(err, utils.ErrFileTooLarge):
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"error": "上传的内容超过Upload-Length",
+				})
+			case errors.Is(err, utils.ErrTusOffsetMismatch):
+				c.JSON(http.StatusConflict, gin.H{
+					"error": err.Error(),
+				})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "保存分块失败: " + err.Error(),
+				})
+			}
+			return
+		}
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if upload.Offset == upload.Length && upload.CompletedAt == nil {
+		if !completeTusUpload(c, upload) {
+			return
+		}
+	}
+	if upload.CompletedAt == nil {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteTusUpload 终止上传并删除已上传的分块（tus termination扩展）
+func DeleteTusUpload(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+
+	upload, ok := findTusUpload(c)
+	if !ok {
+		return
+	}
+
+	unlock, ok := utils.LockTusUpload(upload)
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "该上传正在接收其他分块",
+		})
+		return
+	}
+	defer unlock()
+
+	if err := utils.DeleteTusUpload(upload); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "删除上传失败: " + err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// completeTusUpload 合并分块并登记到媒体库，失败时写入错误响应
+// 文件内容不合法或超出配额时删除整个上传
+func completeTusUpload(c *gin.Context, upload *models.TusUpload) bool {
+	reader, err := utils.OpenTusUpload(upload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "读取分块失败: " + err.Error(),
+		})
+		return false
+	}
+	defer reader.Close()
+
+	head, body, err := utils.ReadHead(reader)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "读取分块失败: " + err.Error(),
+		})
+		return false
+	}
+
+	contentType, err := utils.ValidateMediaContent(upload.FileName, upload.ContentType, head)
+	if err != nil {
+		utils.DeleteTusUpload(upload)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+
+	// 创建时已检查过配额，这里再检查一次，防止并发上传超出配额
+	if _, ok := checkUploadQuota(c, upload.OwnerID, upload.Length); !ok {
+		utils.DeleteTusUpload(upload)
+		return false
+	}
+
+	fileName := utils.GenerateMediaKey(upload.FileName, upload.Private)
+	inspector := utils.NewImageInspector()
+	if err := utils.Storage.Put(fileName, io.TeeReader(body, inspector), upload.Length, contentType); err != nil {
+		utils.Storage.Delete(fileName)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "合并分块失败: " + err.Error(),
+		})
+		return false
+	}
+
+	media, err := createMedia(c, fileName, upload.FileName, upload.OwnerID, inspector.Meta())
+	if err != nil {
+		utils.Storage.Delete(fileName)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "保存媒体记录失败: " + err.Error(),
+		})
+		return false
+	}
+
+	now := time.Now()
+	upload.MediaID = &media.ID
+	upload.CompletedAt = &now
+	models.DB.Model(upload).Updates(map[string]interface{}{
+		"media_id":     media.ID,
+		"completed_at": now,
+	})
+	utils.DeleteTusChunks(upload.ID)
+	return true
+}
+
+// checkTusResumable 检查客户端使用的tus协议版本
+func checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", utils.TusVersion)
+	if c.GetHeader("Tus-Resumable") != utils.TusVersion {
+		c.Header("Tus-Version", utils.TusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "不支持的tus协议版本",
+		})
+		return false
+	}
+	return true
+}
+
+// findTusUpload 查找当前用户的上传会话，不存在或已过期时写入错误响应
+func findTusUpload(c *gin.Context) (*models.TusUpload, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权",
+		})
+		return nil, false
+	}
+
+	var upload models.TusUpload
+	if err := models.DB.Where("id = ? AND owner_id = ?", c.Param("id"), userID).First(&upload).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "上传不存在",
+		})
+		return nil, false
+	}
+
+	if upload.CompletedAt == nil && upload.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "上传已过期",
+		})
+		return nil, false
+	}
+
+	return &upload, true
+}
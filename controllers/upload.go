@@ -1,28 +1,60 @@
 package controllers
 
 import (
+	"blog-server/config"
+	"blog-server/models"
 	"blog-server/utils"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"path/filepath"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type PresignedURLRequest struct {
 	FileName    string `json:"file_name" binding:"required"`
 	ContentType string `json:"content_type" binding:"required"`
+	FileSize    int64  `json:"file_size" binding:"required,min=1"` // 文件大小（字节），上传时必须一致
+	Private     bool   `json:"private"`                            // 私有文件，只能通过签名URL访问
 }
 
+// PresignedURLResponse 预签名上传信息
+// 签名绑定了Content-Type和Content-Length，客户端必须用PUT上传与file_size完全相同大小的文件，
+// 并携带upload_headers中的请求头，大小或类型不一致时存储会拒绝上传
 type PresignedURLResponse struct {
-	UploadURL string `json:"upload_url"`
-	PublicURL string `json:"public_url"`
-	FileName  string `json:"file_name"`
+	UploadURL     string            `json:"upload_url"`
+	UploadMethod  string            `json:"upload_method"`
+	UploadHeaders map[string]string `json:"upload_headers"`
+	PublicURL     string            `json:"public_url"`
+	FileName      string            `json:"file_name"`
+	MaxSize       int64             `json:"max_size"`
+	UploadToken   string            `json:"upload_token"` // 上传完成后调用确认接口时使用
 }
 
-// DeleteImage 删除图片
+type ConfirmUploadRequest struct {
+	FileName     string `json:"file_name" binding:"required"`    // 预签名接口返回的file_name
+	UploadToken  string `json:"upload_token" binding:"required"` // 预签名接口返回的upload_token
+	OriginalName string `json:"original_name"`                   // 原始文件名（可选）
+}
+
+type UploadImageResponse struct {
+	PublicURL    string        `json:"public_url"`
+	FileName     string        `json:"file_name"`
+	ContentType  string        `json:"content_type"`
+	Size         int64         `json:"size"`
+	Deduplicated bool          `json:"deduplicated"` // 是否复用了已有的相同文件
+	Media        *models.Media `json:"media"`
+}
+
+// DeleteImage 根据URL删除图片，仅上传者或管理员可删除
 func DeleteImage(c *gin.Context) {
 	// 检查认证
-	_, exists := c.Get("user_id")
+	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "未授权",
@@ -42,15 +74,6 @@ func DeleteImage(c *gin.Context) {
 		return
 	}
 
-	// 从URL中提取文件名
-	fileName := utils.ExtractFileNameFromURL(req.URL)
-	if fileName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "无效的图片URL",
-		})
-		return
-	}
-
 	// 初始化存储服务（如果还未初始化）
 	if utils.Storage == nil {
 		if err := utils.InitStorage(); err != nil {
@@ -61,23 +84,53 @@ func DeleteImage(c *gin.Context) {
 		}
 	}
 
-	// 删除图片
-	if err := utils.Storage.DeleteImage(fileName); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "图片删除失败: " + err.Error(),
+	// 内容重复的上传共享URL和对象键，优先删除当前用户自己的记录
+	// 管理员没有自己的记录时可删除其他用户的记录
+	uid := userID.(uint)
+	media, err := findMediaByURL(req.URL, uid)
+	if err != nil && isAdminUser(uid) {
+		media, err = findMediaByURL(req.URL, 0)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "图片不存在",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "图片删除成功",
-	})
+	deleteMedia(c, media)
+}
+
+// findMediaByURL 优先按记录的URL查找媒体，公共URL配置变更后再按对象键查找
+// ownerID不为0时只查找该用户的记录
+func findMediaByURL(rawURL string, ownerID uint) (*models.Media, error) {
+	scoped := func() *gorm.DB {
+		query := models.DB.Model(&models.Media{})
+		if ownerID != 0 {
+			query = query.Where("owner_id = ?", ownerID)
+		}
+		return query
+	}
+
+	var media models.Media
+	err := scoped().Where("url = ?", rawURL).Order("id").First(&media).Error
+	if err == nil {
+		return &media, nil
+	}
+	if key := utils.KeyFromPublicURL(rawURL); key != "" {
+		err = scoped().Where("key = ?", key).Order("id").First(&media).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &media, nil
 }
 
 // GetPresignedURL 获取预签名上传URL
+// 客户端上传完成后需调用ConfirmUpload登记到媒体库
 func GetPresignedURL(c *gin.Context) {
 	// 检查认证
-	_, exists := c.Get("user_id")
+	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "未授权",
@@ -93,13 +146,28 @@ func GetPresignedURL(c *gin.Context) {
 		return
 	}
 
-	// 验证文件类型
-	if !isValidImageType(req.FileName) {
+	// 验证文件类型，声明的类型必须与扩展名一致
+	if err := utils.ValidateImageDeclaration(req.FileName, req.ContentType); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "不支持的文件类型",
+			"error": err.Error(),
 		})
 		return
 	}
+	contentType := utils.NormalizeContentType(req.ContentType)
+
+	// 验证文件大小
+	maxSize := config.AppConfig.MaxUploadSize
+	if req.FileSize > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("文件大小超过限制（最大%d字节）", maxSize),
+		})
+		return
+	}
+
+	// 检查存储配额
+	if _, ok := checkUploadQuota(c, userID.(uint), req.FileSize); !ok {
+		return
+	}
 
 	// 初始化存储服务（如果还未初始化）
 	if utils.Storage == nil {
@@ -111,11 +179,11 @@ func GetPresignedURL(c *gin.Context) {
 		}
 	}
 
-	// 生成唯一文件名
-	fileName := utils.GenerateUniqueFileName(req.FileName)
+	// 生成唯一文件名，私有文件放在私有前缀下
+	fileName := utils.GenerateMediaKey(req.FileName, req.Private)
 
-	// 生成预签名URL
-	uploadURL, err := utils.Storage.GeneratePresignedURL(fileName, req.ContentType)
+	// 生成预签名URL，签名绑定文件大小
+	uploadURL, err := utils.Storage.PresignUpload(fileName, contentType, req.FileSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "生成预签名URL失败: " + err.Error(),
@@ -127,21 +195,289 @@ func GetPresignedURL(c *gin.Context) {
 	publicURL := utils.GeneratePublicURL(fileName)
 
 	c.JSON(http.StatusOK, PresignedURLResponse{
-		UploadURL: uploadURL,
-		PublicURL: publicURL,
-		FileName:  fileName,
+		UploadURL:    uploadURL,
+		UploadMethod: http.MethodPut,
+		UploadHeaders: map[string]string{
+			"Content-Type":   contentType,
+			"Content-Length": strconv.FormatInt(req.FileSize, 10),
+		},
+		PublicURL:   publicURL,
+		FileName:    fileName,
+		MaxSize:     maxSize,
+		UploadToken: utils.SignUploadToken(fileName, userID.(uint), req.FileSize),
 	})
 }
 
-// isValidImageType 验证是否为有效的图片类型
-func isValidImageType(filename string) bool {
-	ext := filepath.Ext(filename)
-	validTypes := []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
-	
-	for _, validType := range validTypes {
-		if ext == validType {
-			return true
+// ConfirmUpload 确认预签名上传已完成，校验文件内容并登记到媒体库
+func ConfirmUpload(c *gin.Context) {
+	// 检查认证
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权",
+		})
+		return
+	}
+
+	var req ConfirmUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 令牌绑定了对象键和上传者，防止登记他人上传的文件
+	size, err := utils.VerifyUploadToken(req.UploadToken, req.FileName, userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 重复确认时直接返回已有记录，内容重复时上传的对象已被删除，需要按上传记录查找
+	var existing models.Media
+	if err := models.DB.Joins("JOIN media_uploads ON media_uploads.media_id = media.id").
+		Where("media_uploads.key = ? AND media.owner_id = ?", req.FileName, userID).
+		First(&existing).Error; err == nil {
+		utils.SignPrivateMedia(&existing)
+		c.JSON(http.StatusOK, existing)
+		return
+	}
+
+	if utils.Storage == nil {
+		if err := utils.InitStorage(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "存储服务初始化失败: " + err.Error(),
+			})
+			return
 		}
 	}
-	return false
-}
\ No newline at end of file
+
+	reader, info, err := utils.Storage.Get(req.FileName)
+	if err != nil {
+		if errors.Is(err, utils.ErrObjectNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "文件尚未上传",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "读取文件失败: " + err.Error(),
+		})
+		return
+	}
+	defer reader.Close()
+
+	if info.Size != size {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "文件大小与声明不一致",
+		})
+		return
+	}
+
+	inspector := utils.NewImageInspector()
+	if _, err := io.Copy(inspector, reader); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "读取文件失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 预签名上传时无法检查内容，这里按文件头补充校验，不合法的文件直接删除
+	if _, err := utils.ValidateImageContent(req.FileName, "", inspector.Head()); err != nil {
+		utils.Storage.Delete(req.FileName)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 预签名时已检查过配额，这里按实际大小再检查一次，防止并发上传超出配额
+	if _, ok := checkUploadQuota(c, userID.(uint), info.Size); !ok {
+		utils.Storage.Delete(req.FileName)
+		return
+	}
+
+	originalName := req.OriginalName
+	if originalName == "" {
+		originalName = filepath.Base(req.FileName)
+	}
+
+	media, err := createMedia(c, req.FileName, originalName, userID.(uint), inspector.Meta())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "保存媒体记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 已有相同内容的文件时，刚上传的文件会被删除，客户端应使用返回的URL
+	c.JSON(http.StatusCreated, gin.H{
+		"media":        media,
+		"public_url":   media.URL,
+		"deduplicated": media.Key != req.FileName,
+	})
+}
+
+// UploadImage 服务端上传图片（multipart/form-data，字段名file）
+// 流式写入存储，根据文件头魔数校验真实类型并限制大小
+// 上传私有文件时使用查询参数private=true，或在file字段之前提交private字段
+func UploadImage(c *gin.Context) {
+	// 检查认证
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权",
+		})
+		return
+	}
+
+	if utils.Storage == nil {
+		if err := utils.InitStorage(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "存储服务初始化失败: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	// 剩余配额小于单文件上限时，按剩余配额限制上传大小
+	user, ok := checkUploadQuota(c, userID.(uint), 1)
+	if !ok {
+		return
+	}
+	maxSize := config.AppConfig.MaxUploadSize
+	limit := maxSize
+	if remaining := utils.RemainingStorage(user); remaining >= 0 && remaining < limit {
+		limit = remaining
+	}
+
+	// 为multipart边界和其他字段预留空间
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+64*1024)
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请使用multipart/form-data上传文件",
+		})
+		return
+	}
+
+	// 找到file字段，不把整个请求缓存到内存或磁盘
+	private := c.Query("private") == "true"
+	var part *multipart.Part
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "读取上传内容失败: " + err.Error(),
+			})
+			return
+		}
+		if p.FormName() == "file" && p.FileName() != "" {
+			part = p
+			break
+		}
+		if p.FormName() == "private" {
+			value, _ := io.ReadAll(io.LimitReader(p, 16))
+			private = string(value) == "true"
+		}
+		p.Close()
+	}
+	if part == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少file字段",
+		})
+		return
+	}
+	defer part.Close()
+
+	originalName := filepath.Base(part.FileName())
+
+	// 读取文件头进行内容嗅探
+	head, body, err := utils.ReadHead(part)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "读取上传内容失败: " + err.Error(),
+		})
+		return
+	}
+
+	contentType, err := utils.ValidateImageContent(originalName, part.Header.Get("Content-Type"), head)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	fileName := utils.GenerateMediaKey(originalName, private)
+	limited := &utils.SizeLimitReader{R: body, Limit: limit}
+	inspector := utils.NewImageInspector()
+	if err := utils.Storage.Put(fileName, io.TeeReader(limited, inspector), -1, contentType); err != nil {
+		if errors.Is(err, utils.ErrFileTooLarge) || limited.N > limit {
+			// 清理可能已写入的部分内容
+			utils.Storage.Delete(fileName)
+			if limit < maxSize {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": utils.ErrStorageQuotaExceeded.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("文件大小超过限制（最大%d字节）", maxSize),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "图片上传失败: " + err.Error(),
+		})
+		return
+	}
+
+	media, err := createMedia(c, fileName, originalName, userID.(uint), inspector.Meta())
+	if err != nil {
+		utils.Storage.Delete(fileName)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "保存媒体记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 已有相同内容的文件时返回已有文件的地址
+	c.JSON(http.StatusCreated, UploadImageResponse{
+		PublicURL:    media.URL,
+		FileName:     media.Key,
+		ContentType:  contentType,
+		Size:         media.Size,
+		Deduplicated: media.Key != fileName,
+		Media:        media,
+	})
+}
+
+// checkUploadQuota 检查用户上传size字节后是否超出存储配额，超出时写入错误响应
+func checkUploadQuota(c *gin.Context, userID uint, size int64) (*models.User, bool) {
+	var user models.User
+	if err := models.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "用户不存在",
+		})
+		return nil, false
+	}
+
+	if err := utils.CheckStorageQuota(&user, size); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":         err.Error(),
+			"storage_used":  user.StorageUsed,
+			"storage_quota": utils.StorageQuotaFor(&user),
+		})
+		return nil, false
+	}
+
+	return &user, true
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"blog-server/config"
+	"blog-server/middleware"
 	"blog-server/models"
 	"blog-server/routes"
 	"blog-server/utils"
@@ -24,6 +25,21 @@ func main() {
 	if err := utils.InitStorage(); err != nil {
 		log.Printf("存储服务初始化失败: %v", err)
 		log.Println("图片上传功能将不可用")
+	} else {
+		// 启动孤立文件扫描和断点续传清理任务
+		utils.StartOrphanScanScheduler()
+		utils.StartTusCleanupScheduler()
+	}
+
+	// 初始化GeoIP数据库
+	if err := utils.InitGeoIP(); err != nil {
+		log.Printf("GeoIP初始化失败: %v", err)
+		log.Println("访问数据将不包含地理位置")
+	}
+
+	// 加载已知爬虫的IP网段
+	if err := utils.InitBotDetector(); err != nil {
+		log.Printf("爬虫IP列表加载失败: %v", err)
 	}
 
 	// 初始化Redis
@@ -41,8 +57,9 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// 创建路由
-	r := gin.Default()
+	// 创建路由，访问日志隐藏查询参数中的令牌
+	r := gin.New()
+	r.Use(middleware.AccessLogMiddleware(), gin.Recovery())
 
 	// 设置代理信任 - 根据环境配置
 	if config.AppConfig.Mode == "release" {
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"blog-server/models"
 	"blog-server/utils"
 	"net/http"
 	"strings"
@@ -44,4 +45,67 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("username", claims.Username)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// StreamAuthMiddleware 实时推送接口的认证中间件
+// 浏览器的EventSource无法设置请求头，除Authorization外也接受token查询参数
+func StreamAuthMiddleware() gin.HandlerFunc {
+	auth := AuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		auth(c)
+	}
+}
+
+// OptionalAuthMiddleware 可选认证中间件，携带有效令牌时设置用户信息，否则按匿名访问继续
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			if claims, err := utils.ParseToken(parts[1]); err == nil {
+				c.Set("user_id", claims.UserID)
+				c.Set("username", claims.Username)
+			}
+		}
+		c.Next()
+	}
+}
+
+// AdminMiddleware 管理员权限中间件，需在AuthMiddleware之后使用
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "未授权",
+			})
+			c.Abort()
+			return
+		}
+
+		// 每次从数据库读取角色，角色变更即时生效
+		var user models.User
+		if err := models.DB.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "用户不存在",
+			})
+			c.Abort()
+			return
+		}
+
+		if !user.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "需要管理员权限",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("role", user.Role)
+		c.Next()
+	}
+}
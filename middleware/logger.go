@@ -5,7 +5,9 @@ import (
 	"blog-server/utils"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net/url"
 	"runtime"
 	"strings"
 	"time"
@@ -13,6 +15,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// maxLoggedBodySize 记录到日志的请求体上限，超过时不记录内容
+const maxLoggedBodySize = 64 << 10
+
 type responseWriter struct {
 	gin.ResponseWriter
 	body       *bytes.Buffer
@@ -20,7 +25,10 @@ type responseWriter struct {
 }
 
 func (w *responseWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+	// 只缓存JSON响应用于提取错误信息，图片、事件流等响应不缓存
+	if isJSONContentType(w.Header().Get("Content-Type")) {
+		w.body.Write(b)
+	}
 	return w.ResponseWriter.Write(b)
 }
 
@@ -43,12 +51,7 @@ func LoggerMiddleware() gin.HandlerFunc {
 		c.Writer = blw
 
 		// 读取请求体
-		var requestBody string
-		if c.Request.Body != nil {
-			bodyBytes, _ := io.ReadAll(c.Request.Body)
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-			requestBody = filterSensitiveData(string(bodyBytes))
-		}
+		requestBody := captureRequestBody(c)
 
 		// 获取调用的函数名
 		functionName := getFunctionName()
@@ -113,6 +116,76 @@ func LoggerMiddleware() gin.HandlerFunc {
 	}
 }
 
+// AccessLogMiddleware 控制台访问日志，格式与gin默认的Logger相同
+// 实时推送接口通过token查询参数传递JWT，输出前将其替换为***，避免令牌写入访问日志
+func AccessLogMiddleware() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor = param.StatusCodeColor()
+			methodColor = param.MethodColor()
+			resetColor = param.ResetColor()
+		}
+
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			redactQueryToken(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
+
+// redactQueryToken 隐藏请求路径中token查询参数的值，其他参数保持原样
+func redactQueryToken(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil && name == "token" {
+			params[i] = key + "=***"
+		}
+	}
+	return base + "?" + strings.Join(params, "&")
+}
+
+// captureRequestBody 读取用于记录的请求体
+// 只记录JSON请求，上传文件、分片等二进制内容不读取，超过上限的请求体也不记录
+// 已读取的部分会放回请求体，不影响后续处理
+func captureRequestBody(c *gin.Context) string {
+	if c.Request.Body == nil || !isJSONContentType(c.ContentType()) {
+		return ""
+	}
+
+	bodyBytes, _ := io.ReadAll(io.LimitReader(c.Request.Body, maxLoggedBodySize+1))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(bodyBytes), c.Request.Body), c.Request.Body}
+
+	if len(bodyBytes) > maxLoggedBodySize {
+		return "[请求体过大，未记录]"
+	}
+	return filterSensitiveData(string(bodyBytes))
+}
+
+// isJSONContentType 判断是否为JSON内容类型，包括application/problem+json等
+func isJSONContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
 // getFunctionName 获取当前处理的函数名
 func getFunctionName() string {
 	pc := make([]uintptr, 15)
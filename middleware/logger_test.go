@@ -0,0 +1,27 @@
+package middleware
+
+import "testing"
+
+func TestRedactQueryToken(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"没有查询参数", "/api/analytics/stream", "/api/analytics/stream"},
+		{"只有令牌", "/api/analytics/stream?token=eyJhbGciOi.abc.def", "/api/analytics/stream?token=***"},
+		{"保留其他参数", "/api/analytics/stream?include_bots=true&token=abc", "/api/analytics/stream?include_bots=true&token=***"},
+		{"重复的令牌参数", "/stream?token=a&token=b", "/stream?token=***&token=***"},
+		{"编码后的参数名", "/stream?%74oken=abc", "/stream?%74oken=***"},
+		{"名称相近的参数", "/stream?upload_token=abc&tokens=1", "/stream?upload_token=abc&tokens=1"},
+		{"空值", "/stream?token=", "/stream?token=***"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactQueryToken(tt.path); got != tt.want {
+				t.Errorf("redactQueryToken(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
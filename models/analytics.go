@@ -11,7 +11,9 @@ type TrackingEvent struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
 	Timestamp   time.Time      `json:"timestamp" gorm:"index;not null"`
 	Path        string         `json:"path" gorm:"not null;index"`
-	IPAddress   string         `json:"ip_address" gorm:"not null;index"`
+	IPAddress   string         `json:"ip_address" gorm:"not null;index"` // 截断后的网段，默认不保存IP
+	VisitorID   string         `json:"visitor_id" gorm:"size:32;index"`   // 由每日更换的盐、IP和User-Agent生成
+	ClientID    string         `json:"client_id" gorm:"size:32;index"`    // 跟踪脚本上报的客户端ID的HMAC，跨天不变，没有上报时为空
 	UserAgent   string         `json:"user_agent_hash" gorm:"not null"`
 	Referer     string         `json:"referer"`
 	EventType   string         `json:"event_type" gorm:"not null;index"` // page_view, article_click, etc.
@@ -19,6 +21,20 @@ type TrackingEvent struct {
 	SessionID   string         `json:"session_id" gorm:"index"`
 	Country     string         `json:"country"`
 	City        string         `json:"city"`
+
+	// 从User-Agent解析出的信息，原始User-Agent只保存哈希
+	Browser        string `json:"browser" gorm:"size:64;index"`
+	BrowserVersion string `json:"browser_version" gorm:"size:32"`
+	OS             string `json:"os" gorm:"size:64;index"`
+	DeviceType     string `json:"device_type" gorm:"size:16;index"` // desktop, mobile, tablet, bot
+
+	// 机器人识别结果，统计默认排除机器人
+	IsBot     bool   `json:"is_bot" gorm:"default:false;index"`
+	BotReason string `json:"bot_reason,omitempty" gorm:"size:16"` // user_agent, ip_range, rate, behavior
+
+	// 自定义事件属性，一层的JSON对象
+	Properties *string `json:"properties,omitempty" gorm:"type:jsonb"`
+
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
@@ -31,6 +47,8 @@ type DailyStats struct {
 	PageViews      int64          `json:"page_views" gorm:"default:0"`
 	UniqueVisitors int64          `json:"unique_visitors" gorm:"default:0"`
 	ArticleClicks  int64          `json:"article_clicks" gorm:"default:0"`
+	BotPageViews   int64          `json:"bot_page_views" gorm:"default:0"` // 机器人产生的事件数，不计入上面的统计
+	BotVisitors    int64          `json:"bot_visitors" gorm:"default:0"`
 	TopPages       string         `json:"top_pages" gorm:"type:jsonb"` // JSON格式存储热门页面
 	TopArticles    string         `json:"top_articles" gorm:"type:jsonb"` // JSON格式存储热门文章
 	CreatedAt      time.Time      `json:"created_at"`
@@ -54,7 +72,89 @@ type PageHeatmap struct {
 	Path      string         `json:"path" gorm:"not null;index"`
 	Clicks    int64          `json:"clicks" gorm:"default:0"`
 	Views     int64          `json:"views" gorm:"default:0"`
+
+	// 访问质量，由QueryPathEngagement在转存时计算
+	UniqueVisitors  int64   `json:"unique_visitors" gorm:"default:0"`
+	Entries         int64   `json:"entries" gorm:"default:0"`           // 作为访问入口页的次数
+	Bounces         int64   `json:"bounces" gorm:"default:0"`           // 只浏览了这一个页面的访问数
+	BounceRate      float64 `json:"bounce_rate" gorm:"default:0"`       // Bounces / Entries
+	TimedViews      int64   `json:"timed_views" gorm:"default:0"`       // 能算出停留时间的浏览数
+	AverageStayTime float64 `json:"average_stay_time" gorm:"default:0"` // 平均停留秒数
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
-}
\ No newline at end of file
+}
+
+// VisitorFirstSeen 访客首次出现的日期，转存时写入，用于留存分析
+// 按跨天不变的客户端ID记录，每天更换的访客ID无法识别回访
+type VisitorFirstSeen struct {
+	ClientID  string    `json:"client_id" gorm:"primaryKey;size:32"`
+	FirstSeen time.Time `json:"first_seen" gorm:"type:date;not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// VisitIdleTimeout 同一访客两次页面访问间隔超过这个时间时视为新的一次访问
+const VisitIdleTimeout = 30 * time.Minute
+
+// PathEngagement 页面的入口、跳出和停留时间统计
+type PathEngagement struct {
+	Path            string  `json:"path"`
+	Entries         int64   `json:"entries"`
+	Bounces         int64   `json:"bounces"`
+	TimedViews      int64   `json:"timed_views"`
+	AverageStayTime float64 `json:"average_stay_time"`
+}
+
+// BounceRate 跳出率，没有作为入口页时为0
+func (e PathEngagement) BounceRate() float64 {
+	if e.Entries == 0 {
+		return 0
+	}
+	return float64(e.Bounces) / float64(e.Entries)
+}
+
+// pathEngagementSQL 用窗口函数按会话计算页面的停留时间和跳出
+// 会话内按时间排序的页面访问，间隔超过VisitIdleTimeout时拆分为新的访问；
+// 停留时间为到同一访问中下一次页面访问的间隔，每次访问的最后一页无法计算，不计入平均值；
+// 每次访问的第一页为入口页，只有一次页面访问的访问计为跳出
+const pathEngagementSQL = `
+WITH views AS (
+	SELECT id, path, session_id, timestamp,
+		LAG(timestamp) OVER (PARTITION BY session_id ORDER BY timestamp, id) AS prev_timestamp
+	FROM tracking_events
+	WHERE deleted_at IS NULL AND event_type = 'page_view'
+		AND timestamp BETWEEN @start AND @end
+		AND (@include_bots OR is_bot = false)
+), visits AS (
+	SELECT id, path, session_id, timestamp,
+		SUM(CASE WHEN prev_timestamp IS NULL OR timestamp - prev_timestamp > make_interval(secs => @idle) THEN 1 ELSE 0 END)
+			OVER (PARTITION BY session_id ORDER BY timestamp, id) AS visit_no
+	FROM views
+), ordered AS (
+	SELECT path,
+		ROW_NUMBER() OVER w AS seq,
+		COUNT(*) OVER (PARTITION BY session_id, visit_no) AS visit_views,
+		EXTRACT(EPOCH FROM LEAD(timestamp) OVER w - timestamp) AS stay
+	FROM visits
+	WINDOW w AS (PARTITION BY session_id, visit_no ORDER BY timestamp, id)
+)
+SELECT path,
+	COUNT(*) FILTER (WHERE seq = 1) AS entries,
+	COUNT(*) FILTER (WHERE seq = 1 AND visit_views = 1) AS bounces,
+	COUNT(stay) AS timed_views,
+	COALESCE(AVG(stay), 0) AS average_stay_time
+FROM ordered
+GROUP BY path`
+
+// QueryPathEngagement 计算时间范围内各页面的入口数、跳出数和平均停留时间
+func QueryPathEngagement(db *gorm.DB, start, end string, includeBots bool) ([]PathEngagement, error) {
+	var engagement []PathEngagement
+	err := db.Raw(pathEngagementSQL, map[string]interface{}{
+		"start":        start,
+		"end":          end,
+		"include_bots": includeBots,
+		"idle":         VisitIdleTimeout.Seconds(),
+	}).Scan(&engagement).Error
+	return engagement, err
+}
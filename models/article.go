@@ -15,6 +15,7 @@ type Article struct {
 	UserID    uint           `json:"user_id" gorm:"not null"`
 	User      User           `json:"user" gorm:"foreignKey:UserID"`
 	Content   *ArticleContent `json:"-" gorm:"foreignKey:ArticleID"` // 关联文章内容，JSON中隐藏
+	Images    map[string]ResponsiveImage `json:"images,omitempty" gorm:"-"` // 正文中引用的媒体库图片，按URL索引
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"` // 软删除
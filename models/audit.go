@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// 审计动作
+const (
+	AuditArticleCreate   = "article.create"
+	AuditArticleUpdate   = "article.update"
+	AuditArticleDelete   = "article.delete"
+	AuditProfileUpdate   = "profile.update"
+	AuditSiteOwnerChange = "profile.site_owner_change"
+	AuditUserCreate      = "user.create"
+	AuditUserRoleChange  = "user.role_change"
+	AuditUserQuotaChange = "user.quota_change"
+	AuditImageDelete     = "image.delete"
+	AuditImageUpload     = "image.upload"
+	AuditOrphanPurge     = "storage.orphan_purge"
+
+	// 资料子项（技能、工作经历、教育经历、项目）
+	AuditProfileSectionCreate  = "profile.section_create"
+	AuditProfileSectionUpdate  = "profile.section_update"
+	AuditProfileSectionDelete  = "profile.section_delete"
+	AuditProfileSectionReorder = "profile.section_reorder"
+)
+
+// AuditLog 业务审计日志，记录谁对什么对象做了什么修改
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	ActorID    *uint     `json:"actor_id" gorm:"index"`                              // 操作者ID
+	ActorName  string    `json:"actor_name"`                                         // 操作者用户名
+	Action     string    `json:"action" gorm:"not null;index"`                       // 动作，如 article.update
+	TargetType string    `json:"target_type" gorm:"not null;index:idx_audit_target"` // 目标类型，如 article
+	TargetID   string    `json:"target_id" gorm:"index:idx_audit_target"`            // 目标ID
+	Before     *string   `json:"before" gorm:"type:jsonb"`                           // 修改前快照（创建时为空）
+	After      *string   `json:"after" gorm:"type:jsonb"`                            // 修改后快照（删除时为空）
+	IP         string    `json:"ip"`                                                 // 操作者IP
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
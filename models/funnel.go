@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultFunnelWindowMinutes 漏斗默认的转化时间窗口
+const DefaultFunnelWindowMinutes = 30
+
+// Funnel 保存的漏斗定义
+// 同一会话中按顺序依次命中各步骤，且从第一步起在时间窗口内完成，才算到达该步骤
+type Funnel struct {
+	ID            uint         `json:"id" gorm:"primaryKey"`
+	Name          string       `json:"name" gorm:"not null"`
+	Description   string       `json:"description"`
+	WindowMinutes int          `json:"window_minutes" gorm:"not null;default:30"`
+	CreatedByID   *uint        `json:"created_by_id"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	Steps         []FunnelStep `json:"steps" gorm:"foreignKey:FunnelID"`
+}
+
+// FunnelStep 漏斗步骤，路径和事件类型至少指定一个，为空表示不限
+// 路径以*结尾时按前缀匹配，否则需要完全相同
+type FunnelStep struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	FunnelID  uint   `json:"funnel_id" gorm:"not null;index"`
+	StepOrder int    `json:"step_order" gorm:"not null"`
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	EventType string `json:"event_type" gorm:"size:32"`
+}
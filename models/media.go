@@ -0,0 +1,134 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 媒体处理状态
+const (
+	MediaStatusPending = "pending" // 等待生成响应式版本
+	MediaStatusReady   = "ready"   // 已生成
+	MediaStatusFailed  = "failed"  // 处理失败
+	MediaStatusSkipped = "skipped" // 不需要处理，如GIF动图
+)
+
+// MediaBlob 实际存储的文件，按内容哈希去重
+// 多条媒体记录可以引用同一个文件，引用数归零时才删除存储对象
+type MediaBlob struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Hash      string    `json:"hash" gorm:"index;size:64;not null"` // 文件内容的SHA-256
+	Key       string    `json:"key" gorm:"uniqueIndex;not null"`    // 存储对象键
+	Size      int64     `json:"size"`
+	MimeType  string    `json:"mime_type"`
+	Private   bool      `json:"private" gorm:"not null;default:false"` // 私有文件存放在私有前缀下，不与公开文件共享
+	RefCount  int       `json:"ref_count" gorm:"not null;default:0"`   // 引用此文件的媒体记录数
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MediaUpload 上传的对象键与媒体记录的对应关系，与媒体记录在同一事务中创建
+// 内容重复时上传的对象会被删除，重复确认同一次上传时按此记录返回已有的媒体
+type MediaUpload struct {
+	Key       string    `json:"key" gorm:"primaryKey"`
+	MediaID   uint      `json:"media_id" gorm:"index;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Media 媒体库记录，用户每次上传（或上传了相同内容）对应一条
+type Media struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	BlobID       uint           `json:"blob_id" gorm:"index"`           // 实际存储的文件
+	Key          string         `json:"key" gorm:"index;not null"`      // 存储对象键，如 images/1700000000_abcd.png
+	URL          string         `json:"url" gorm:"index;not null"`      // 上传时的访问URL，私有文件需换成签名URL才能访问
+	OwnerID      uint           `json:"owner_id" gorm:"index;not null"` // 上传者
+	OriginalName string         `json:"original_name"`                  // 上传时的原始文件名
+	Size         int64          `json:"size"`                           // 文件大小（字节）
+	MimeType     string         `json:"mime_type" gorm:"index"`         // 根据文件内容识别的类型
+	Width        int            `json:"width"`                          // 图片宽度（像素）
+	Height       int            `json:"height"`                         // 图片高度（像素）
+	Hash         string         `json:"hash" gorm:"index;size:64"`      // 文件内容的SHA-256
+	Status       string         `json:"status" gorm:"not null;default:pending;index"`
+	Private      bool           `json:"private" gorm:"not null;default:false;index"` // 私有文件只能通过有时效的签名URL访问
+	SignedURL    string         `json:"signed_url,omitempty" gorm:"-"`               // 私有文件的临时访问地址
+	CreatedAt    time.Time      `json:"created_at" gorm:"index"`                     // 上传时间
+	Owner        *User          `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
+	Variants     []MediaVariant `json:"variants,omitempty" gorm:"foreignKey:BlobID;references:BlobID;constraint:-"`
+}
+
+// MediaVariant 文件的缩放或转码版本，由引用同一文件的媒体记录共享
+type MediaVariant struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	BlobID    uint      `json:"blob_id" gorm:"index"`
+	Key       string    `json:"key" gorm:"uniqueIndex;not null"`
+	URL       string    `json:"url" gorm:"not null"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Size      int64     `json:"size"`
+	MimeType  string    `json:"mime_type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ResponsiveImage 可直接用于<img srcset>和<picture>的响应式图片信息
+type ResponsiveImage struct {
+	Src     string             `json:"src"`
+	Type    string             `json:"type"`
+	Width   int                `json:"width"`
+	Height  int                `json:"height"`
+	Srcset  string             `json:"srcset"`            // 与原图相同格式的各尺寸版本
+	Sources []ResponsiveSource `json:"sources,omitempty"` // 其他格式（如WebP），用于<source>
+}
+
+// ResponsiveSource <picture>中的一个<source>
+type ResponsiveSource struct {
+	Type   string `json:"type"`
+	Srcset string `json:"srcset"`
+}
+
+// Responsive 根据已加载的版本生成响应式图片信息
+func (m *Media) Responsive() ResponsiveImage {
+	byType := map[string][]string{}
+	var types []string
+
+	variants := append([]MediaVariant(nil), m.Variants...)
+	sort.Slice(variants, func(i, j int) bool {
+		return variants[i].Width < variants[j].Width
+	})
+
+	for _, variant := range variants {
+		if _, ok := byType[variant.MimeType]; !ok && variant.MimeType != m.MimeType {
+			types = append(types, variant.MimeType)
+		}
+		byType[variant.MimeType] = append(byType[variant.MimeType], srcsetEntry(variant.URL, variant.Width))
+	}
+	// 私有文件使用签名后的地址
+	src := m.URL
+	if m.SignedURL != "" {
+		src = m.SignedURL
+	}
+
+	// 缩放版本都比原图小，原图放在最后
+	if m.Width > 0 {
+		byType[m.MimeType] = append(byType[m.MimeType], srcsetEntry(src, m.Width))
+	}
+
+	image := ResponsiveImage{
+		Src:    src,
+		Type:   m.MimeType,
+		Width:  m.Width,
+		Height: m.Height,
+		Srcset: strings.Join(byType[m.MimeType], ", "),
+	}
+	for _, mimeType := range types {
+		image.Sources = append(image.Sources, ResponsiveSource{
+			Type:   mimeType,
+			Srcset: strings.Join(byType[mimeType], ", "),
+		})
+	}
+	return image
+}
+
+func srcsetEntry(url string, width int) string {
+	return fmt.Sprintf("%s %dw", url, width)
+}
@@ -1,8 +1,14 @@
 package models
 
 import (
+	"blog-server/config"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -131,6 +137,540 @@ var migrations = []MigrationItem{
 			return db.Migrator().DropTable(&TrackingEvent{}, &DailyStats{}, &PageHeatmap{})
 		},
 	},
+	{
+		Version: "005",
+		Name:    "add_user_role_and_audit_log",
+		Up: func(db *gorm.DB) error {
+			hadRole := db.Migrator().HasColumn(&User{}, "role")
+			if err := db.AutoMigrate(&User{}, &AuditLog{}); err != nil {
+				return err
+			}
+
+			// 之前注册的用户都需要密令，视为管理员
+			if !hadRole {
+				if err := db.Model(&User{}).Where("1 = 1").Update("role", RoleAdmin).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.Migrator().DropTable(&AuditLog{}); err != nil {
+				return err
+			}
+			return db.Migrator().DropColumn(&User{}, "role")
+		},
+	},
+	{
+		Version: "006",
+		Name:    "link_profile_to_user",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(&Profile{}); err != nil {
+				return err
+			}
+
+			// 原来全站只有一份资料，将其关联到最早的管理员并设为站点主人
+			var profile Profile
+			if err := db.Where("user_id IS NULL").Order("id ASC").First(&profile).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return nil
+				}
+				return err
+			}
+
+			var owner User
+			if err := db.Where("role = ?", RoleAdmin).Order("id ASC").First(&owner).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return db.Model(&profile).Update("is_site_owner", true).Error
+				}
+				return err
+			}
+
+			return db.Model(&profile).Updates(map[string]interface{}{
+				"user_id":       owner.ID,
+				"is_site_owner": true,
+			}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.Migrator().DropColumn(&Profile{}, "is_site_owner"); err != nil {
+				return err
+			}
+			return db.Migrator().DropColumn(&Profile{}, "user_id")
+		},
+	},
+	{
+		Version: "007",
+		Name:    "create_profile_sections",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(&ProfileSkill{}, &ProfileExperience{}, &ProfileEducation{}, &ProfileProject{}); err != nil {
+				return err
+			}
+
+			// 将旧的Skills文本字段迁移为结构化技能
+			var profiles []Profile
+			if err := db.Where("skills IS NOT NULL AND skills != ''").Find(&profiles).Error; err != nil {
+				return err
+			}
+
+			for _, profile := range profiles {
+				for i, skill := range parseLegacySkills(profile.Skills) {
+					skill.ProfileID = profile.ID
+					skill.SortOrder = i
+					if err := db.Create(&skill).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(&ProfileSkill{}, &ProfileExperience{}, &ProfileEducation{}, &ProfileProject{})
+		},
+	},
+	{
+		Version: "008",
+		Name:    "create_media_table",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&Media{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(&Media{})
+		},
+	},
+	{
+		Version: "009",
+		Name:    "create_media_variants",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&Media{}, &MediaVariant{})
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.Migrator().DropTable(&MediaVariant{}); err != nil {
+				return err
+			}
+			return db.Migrator().DropColumn(&Media{}, "status")
+		},
+	},
+	{
+		Version: "010",
+		Name:    "create_orphan_scans",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&OrphanScan{}, &OrphanObject{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(&OrphanObject{}, &OrphanScan{})
+		},
+	},
+	{
+		Version: "011",
+		Name:    "add_user_storage_quota",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(&User{}); err != nil {
+				return err
+			}
+
+			// 版本表创建时已按文件关联（见012迁移）的数据库没有按媒体记录的版本，存储用量由012迁移计算
+			if !db.Migrator().HasColumn(&MediaVariant{}, "media_id") {
+				return nil
+			}
+
+			// 根据已登记的媒体和版本计算现有用户的存储用量
+			return db.Exec(`UPDATE users SET storage_used =
+				COALESCE((SELECT SUM(size) FROM media WHERE media.owner_id = users.id), 0) +
+				COALESCE((SELECT SUM(media_variants.size) FROM media_variants
+					JOIN media ON media.id = media_variants.media_id
+					WHERE media.owner_id = users.id), 0)`).Error
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.Migrator().DropColumn(&User{}, "storage_quota"); err != nil {
+				return err
+			}
+			return db.Migrator().DropColumn(&User{}, "storage_used")
+		},
+	},
+	{
+		Version: "012",
+		Name:    "create_media_blobs",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(&MediaBlob{}); err != nil {
+				return err
+			}
+
+			// 对象键不再唯一，多条媒体记录可以引用同一个文件
+			if db.Migrator().HasIndex(&Media{}, "idx_media_key") {
+				if err := db.Migrator().DropIndex(&Media{}, "idx_media_key"); err != nil {
+					return err
+				}
+			}
+			if err := db.AutoMigrate(&Media{}, &MediaVariant{}); err != nil {
+				return err
+			}
+
+			// 为已有媒体记录创建对应的文件
+			var mediaList []Media
+			if err := db.Where("blob_id IS NULL OR blob_id = 0").Find(&mediaList).Error; err != nil {
+				return err
+			}
+			for _, media := range mediaList {
+				blob := MediaBlob{
+					Hash:     media.Hash,
+					Key:      media.Key,
+					Size:     media.Size,
+					MimeType: media.MimeType,
+					RefCount: 1,
+				}
+				if err := db.Create(&blob).Error; err != nil {
+					return err
+				}
+				if err := db.Model(&Media{}).Where("id = ?", media.ID).Update("blob_id", blob.ID).Error; err != nil {
+					return err
+				}
+			}
+
+			// 已生成的版本改为关联到文件
+			if db.Migrator().HasColumn(&MediaVariant{}, "media_id") {
+				if err := db.Exec(`UPDATE media_variants SET blob_id = media.blob_id
+					FROM media WHERE media.id = media_variants.media_id`).Error; err != nil {
+					return err
+				}
+				if err := db.Migrator().DropColumn(&MediaVariant{}, "media_id"); err != nil {
+					return err
+				}
+			}
+
+			// 重新计算存储用量：原图及其所有版本计入每条引用记录的上传者
+			return db.Exec(`UPDATE users SET storage_used =
+				COALESCE((SELECT SUM(size) FROM media WHERE media.owner_id = users.id), 0) +
+				COALESCE((SELECT SUM(media_variants.size) FROM media
+					JOIN media_variants ON media_variants.blob_id = media.blob_id
+					WHERE media.owner_id = users.id), 0)`).Error
+		},
+		Down: func(db *gorm.DB) error {
+			// 版本无法再关联到单条媒体记录，删除后需重新处理
+			if err := db.Exec("DELETE FROM media_variants").Error; err != nil {
+				return err
+			}
+			if err := db.Model(&Media{}).Where("1 = 1").Update("status", MediaStatusPending).Error; err != nil {
+				return err
+			}
+			if err := db.Migrator().DropColumn(&MediaVariant{}, "blob_id"); err != nil {
+				return err
+			}
+			if err := db.Migrator().DropColumn(&Media{}, "blob_id"); err != nil {
+				return err
+			}
+			return db.Migrator().DropTable(&MediaBlob{})
+		},
+	},
+	{
+		Version: "013",
+		Name:    "create_tus_uploads",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&TusUpload{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(&TusUpload{})
+		},
+	},
+	{
+		Version: "014",
+		Name:    "add_media_private",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&MediaBlob{}, &Media{}, &TusUpload{})
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.Migrator().DropColumn(&TusUpload{}, "private"); err != nil {
+				return err
+			}
+			if err := db.Migrator().DropColumn(&Media{}, "private"); err != nil {
+				return err
+			}
+			return db.Migrator().DropColumn(&MediaBlob{}, "private")
+		},
+	},
+	{
+		Version: "015",
+		Name:    "add_tracking_user_agent_fields",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&TrackingEvent{})
+		},
+		Down: func(db *gorm.DB) error {
+			for _, column := range []string{"browser", "browser_version", "os", "device_type"} {
+				if err := db.Migrator().DropColumn(&TrackingEvent{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: "016",
+		Name:    "add_bot_classification",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&TrackingEvent{}, &DailyStats{})
+		},
+		Down: func(db *gorm.DB) error {
+			for _, column := range []string{"bot_page_views", "bot_visitors"} {
+				if err := db.Migrator().DropColumn(&DailyStats{}, column); err != nil {
+					return err
+				}
+			}
+			for _, column := range []string{"is_bot", "bot_reason"} {
+				if err := db.Migrator().DropColumn(&TrackingEvent{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: "017",
+		Name:    "anonymize_tracking_visitors",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(&TrackingEvent{}); err != nil {
+				return err
+			}
+
+			// 旧的会话ID是IP和User-Agent的MD5，可以被穷举还原，用一次性的随机盐重新哈希
+			// 同一会话的事件仍然有相同的会话ID和访客ID
+			random := make([]byte, 32)
+			if _, err := rand.Read(random); err != nil {
+				return err
+			}
+			salt := hex.EncodeToString(random)
+			if err := db.Exec(`UPDATE tracking_events
+				SET visitor_id = md5(? || ':visitor:' || session_id), session_id = md5(? || ':session:' || session_id)
+				WHERE visitor_id IS NULL OR visitor_id = ''`, salt, salt).Error; err != nil {
+				return err
+			}
+
+			if config.AppConfig.IPStorageMode != "truncate" {
+				return db.Exec("UPDATE tracking_events SET ip_address = ''").Error
+			}
+
+			var ips []string
+			if err := db.Unscoped().Model(&TrackingEvent{}).Distinct("ip_address").Pluck("ip_address", &ips).Error; err != nil {
+				return err
+			}
+			for _, ip := range ips {
+				if err := db.Unscoped().Model(&TrackingEvent{}).Where("ip_address = ?", ip).
+					Update("ip_address", truncateLegacyIP(ip)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			// 原始IP和会话ID无法恢复，只删除访客ID列
+			return db.Migrator().DropColumn(&TrackingEvent{}, "visitor_id")
+		},
+	},
+	{
+		Version: "018",
+		Name:    "add_page_heatmap_engagement",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(&PageHeatmap{}); err != nil {
+				return err
+			}
+
+			// 按已保存的追踪事件补算历史数据
+			if err := db.Exec(`UPDATE page_heatmaps SET unique_visitors = s.visitors
+				FROM (SELECT DATE(timestamp) AS date, path, COUNT(DISTINCT visitor_id) AS visitors
+					FROM tracking_events WHERE deleted_at IS NULL AND is_bot = false
+					GROUP BY DATE(timestamp), path) s
+				WHERE DATE(page_heatmaps.date) = s.date AND page_heatmaps.path = s.path`).Error; err != nil {
+				return err
+			}
+
+			var dates []time.Time
+			if err := db.Model(&PageHeatmap{}).Distinct("date").Pluck("date", &dates).Error; err != nil {
+				return err
+			}
+			for _, date := range dates {
+				day := date.Format("2006-01-02")
+				engagement, err := QueryPathEngagement(db, day+" 00:00:00", day+" 23:59:59", false)
+				if err != nil {
+					return err
+				}
+				for _, e := range engagement {
+					if err := db.Model(&PageHeatmap{}).Where("date = ? AND path = ?", date, e.Path).Updates(map[string]interface{}{
+						"entries":           e.Entries,
+						"bounces":           e.Bounces,
+						"bounce_rate":       e.BounceRate(),
+						"timed_views":       e.TimedViews,
+						"average_stay_time": e.AverageStayTime,
+					}).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			for _, column := range []string{"unique_visitors", "entries", "bounces", "bounce_rate", "timed_views", "average_stay_time"} {
+				if err := db.Migrator().DropColumn(&PageHeatmap{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: "019",
+		Name:    "create_funnels",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&Funnel{}, &FunnelStep{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(&FunnelStep{}, &Funnel{})
+		},
+	},
+	{
+		Version: "020",
+		Name:    "create_visitor_first_seen",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&VisitorFirstSeen{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(&VisitorFirstSeen{})
+		},
+	},
+	{
+		Version: "021",
+		Name:    "add_tracking_event_properties",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&TrackingEvent{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropColumn(&TrackingEvent{}, "properties")
+		},
+	},
+	{
+		Version: "022",
+		Name:    "add_orphan_scan_media_count",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(&OrphanScan{}); err != nil {
+				return err
+			}
+			// 媒体库的文件不再列入孤立文件报告，不再需要对应的媒体记录
+			if db.Migrator().HasColumn(&OrphanObject{}, "media_id") {
+				return db.Migrator().DropColumn(&OrphanObject{}, "media_id")
+			}
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.Exec("ALTER TABLE orphan_objects ADD COLUMN IF NOT EXISTS media_id bigint").Error; err != nil {
+				return err
+			}
+			return db.Migrator().DropColumn(&OrphanScan{}, "media_count")
+		},
+	},
+	{
+		Version: "023",
+		Name:    "create_media_uploads",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&MediaUpload{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropTable(&MediaUpload{})
+		},
+	},
+	{
+		Version: "024",
+		Name:    "add_tus_upload_lock",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&TusUpload{})
+		},
+		Down: func(db *gorm.DB) error {
+			if err := db.Migrator().DropColumn(&TusUpload{}, "locked_until"); err != nil {
+				return err
+			}
+			return db.Migrator().DropColumn(&TusUpload{}, "lock_token")
+		},
+	},
+	{
+		Version: "025",
+		Name:    "key_visitor_first_seen_by_client_id",
+		Up: func(db *gorm.DB) error {
+			if err := db.AutoMigrate(&TrackingEvent{}); err != nil {
+				return err
+			}
+			// 按每天更换的访客ID记录的首次出现日期无法识别回访，重建为按客户端ID记录
+			if db.Migrator().HasColumn(&VisitorFirstSeen{}, "visitor_id") {
+				if err := db.Migrator().DropTable(&VisitorFirstSeen{}); err != nil {
+					return err
+				}
+			}
+			return db.AutoMigrate(&VisitorFirstSeen{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropColumn(&TrackingEvent{}, "client_id")
+		},
+	},
+	{
+		Version: "026",
+		Name:    "add_orphan_object_media_id",
+		Up: func(db *gorm.DB) error {
+			// 未被引用的媒体库文件重新列入孤立文件报告，记录对应的媒体
+			return db.AutoMigrate(&OrphanObject{})
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Migrator().DropColumn(&OrphanObject{}, "media_id")
+		},
+	},
+}
+
+// truncateLegacyIP 将旧数据中的IP截断为网段，IPv4保留前24位，IPv6保留前48位
+func truncateLegacyIP(ipAddress string) string {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return ""
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// parseLegacySkills 解析旧的技能字段
+// 支持字符串数组、对象数组（name/level/category）以及逗号分隔的纯文本
+func parseLegacySkills(raw string) []ProfileSkill {
+	var skills []ProfileSkill
+
+	var names []string
+	if err := json.Unmarshal([]byte(raw), &names); err == nil {
+		for _, name := range names {
+			if name = strings.TrimSpace(name); name != "" {
+				skills = append(skills, ProfileSkill{Name: name})
+			}
+		}
+		return skills
+	}
+
+	var items []struct {
+		Name     string `json:"name"`
+		Level    string `json:"level"`
+		Category string `json:"category"`
+	}
+	if err := json.Unmarshal([]byte(raw), &items); err == nil {
+		for _, item := range items {
+			if name := strings.TrimSpace(item.Name); name != "" {
+				level := strings.ToLower(strings.TrimSpace(item.Level))
+				if !IsValidSkillLevel(level) {
+					level = ""
+				}
+				skills = append(skills, ProfileSkill{Name: name, Level: level, Category: item.Category})
+			}
+		}
+		return skills
+	}
+
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			skills = append(skills, ProfileSkill{Name: name})
+		}
+	}
+	return skills
 }
 
 // RunMigrations 执行所有未应用的迁移
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// 孤立文件扫描的触发方式
+const (
+	OrphanScanScheduled = "scheduled" // 定时任务
+	OrphanScanManual    = "manual"    // 管理员手动触发
+)
+
+// OrphanScan 孤立文件扫描报告
+// 扫描本身只生成报告（dry-run），需要管理员确认后才会删除
+type OrphanScan struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	Trigger         string         `json:"trigger" gorm:"not null"`
+	GraceHours      int64          `json:"grace_hours"`      // 宽限期，晚于此时间上传的文件不视为孤立
+	ObjectCount     int            `json:"object_count"`     // 扫描的存储对象数量
+	ReferencedCount int            `json:"referenced_count"` // 被文章或资料引用的对象数量
+	MediaCount      int            `json:"media_count"`      // 报告中属于媒体库的文件数量
+	OrphanCount     int            `json:"orphan_count"`
+	OrphanSize      int64          `json:"orphan_size"` // 孤立文件总大小（字节）
+	PurgedCount     int            `json:"purged_count"`
+	PurgedByID      *uint          `json:"purged_by_id"`
+	PurgedAt        *time.Time     `json:"purged_at"`
+	CreatedAt       time.Time      `json:"created_at" gorm:"index"`
+	Objects         []OrphanObject `json:"objects,omitempty" gorm:"foreignKey:ScanID"`
+}
+
+// OrphanObject 扫描发现的孤立文件
+type OrphanObject struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	ScanID       uint       `json:"scan_id" gorm:"index;not null"`
+	Key          string     `json:"key" gorm:"not null"`
+	URL          string     `json:"url"`
+	Size         int64      `json:"size"`
+	LastModified time.Time  `json:"last_modified"`
+	MediaID      *uint      `json:"media_id"` // 对应的媒体库记录，删除时一并删除引用该文件的所有媒体记录
+	PurgedAt     *time.Time `json:"purged_at"`
+	Skipped      bool       `json:"skipped"` // 清理时发现已被重新引用或重新上传，未删除
+}
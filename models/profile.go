@@ -2,22 +2,119 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Profile struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      *uint     `json:"user_id" gorm:"uniqueIndex"`         // 所属用户，每个用户一份资料
+	IsSiteOwner bool      `json:"is_site_owner" gorm:"default:false"` // 是否为站点主人（/api/profile返回的资料）
+	Name        string    `json:"name" gorm:"not null"`
+	Email       string    `json:"email"`
+	Bio         string    `json:"bio" gorm:"type:text"`    // 个人介绍
+	Skills      string    `json:"skills" gorm:"type:text"` // 技能（旧字段，已迁移到SkillItems）
+	Avatar      string    `json:"avatar"`                  // 头像URL
+	Website     string    `json:"website"`                 // 个人网站
+	GitHub      string    `json:"github"`                  // GitHub链接
+	LinkedIn    string    `json:"linkedin"`                // LinkedIn链接
+	Twitter     string    `json:"twitter"`                 // Twitter链接
+	Location    string    `json:"location"`                // 所在地
+	Company     string    `json:"company"`                 // 公司
+	Position    string    `json:"position"`                // 职位
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// 结构化的简历信息
+	SkillItems  []ProfileSkill      `json:"skill_items" gorm:"foreignKey:ProfileID"`
+	Experiences []ProfileExperience `json:"experiences" gorm:"foreignKey:ProfileID"`
+	Educations  []ProfileEducation  `json:"educations" gorm:"foreignKey:ProfileID"`
+	Projects    []ProfileProject    `json:"projects" gorm:"foreignKey:ProfileID"`
+}
+
+// PreloadProfileSections 按排序预加载资料的技能、经历、教育和项目
+func PreloadProfileSections(db *gorm.DB) *gorm.DB {
+	ordered := func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort_order ASC, id ASC")
+	}
+	return db.Preload("SkillItems", ordered).
+		Preload("Experiences", ordered).
+		Preload("Educations", ordered).
+		Preload("Projects", ordered)
+}
+
+// 技能熟练度
+const (
+	SkillLevelBeginner     = "beginner"
+	SkillLevelIntermediate = "intermediate"
+	SkillLevelAdvanced     = "advanced"
+	SkillLevelExpert       = "expert"
+)
+
+// ProfileSkill 技能
+type ProfileSkill struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
+	ProfileID uint      `json:"profile_id" gorm:"not null;index"`
 	Name      string    `json:"name" gorm:"not null"`
-	Email     string    `json:"email"`
-	Bio       string    `json:"bio" gorm:"type:text"`    // 个人介绍
-	Skills    string    `json:"skills" gorm:"type:text"` // 技能，JSON格式存储
-	Avatar    string    `json:"avatar"`                  // 头像URL
-	Website   string    `json:"website"`                 // 个人网站
-	GitHub    string    `json:"github"`                  // GitHub链接
-	LinkedIn  string    `json:"linkedin"`                // LinkedIn链接
-	Twitter   string    `json:"twitter"`                 // Twitter链接
-	Location  string    `json:"location"`                // 所在地
-	Company   string    `json:"company"`                 // 公司
-	Position  string    `json:"position"`                // 职位
+	Level     string    `json:"level"`    // beginner, intermediate, advanced, expert
+	Category  string    `json:"category"` // 分类，如 后端、前端、运维
+	SortOrder int       `json:"sort_order" gorm:"default:0"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// ProfileExperience 工作经历
+type ProfileExperience struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ProfileID   uint      `json:"profile_id" gorm:"not null;index"`
+	Company     string    `json:"company" gorm:"not null"`
+	Position    string    `json:"position" gorm:"not null"`
+	Location    string    `json:"location"`
+	URL         string    `json:"url"`        // 公司网站
+	StartDate   string    `json:"start_date"` // YYYY-MM
+	EndDate     string    `json:"end_date"`   // YYYY-MM，为空表示至今
+	Description string    `json:"description" gorm:"type:text"`
+	SortOrder   int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ProfileEducation 教育经历
+type ProfileEducation struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ProfileID   uint      `json:"profile_id" gorm:"not null;index"`
+	Institution string    `json:"institution" gorm:"not null"` // 学校
+	Degree      string    `json:"degree"`                      // 学位，如 本科、硕士
+	Field       string    `json:"field"`                       // 专业
+	StartDate   string    `json:"start_date"`                  // YYYY-MM
+	EndDate     string    `json:"end_date"`                    // YYYY-MM，为空表示至今
+	Description string    `json:"description" gorm:"type:text"`
+	SortOrder   int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ProfileProject 项目作品
+type ProfileProject struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ProfileID    uint      `json:"profile_id" gorm:"not null;index"`
+	Name         string    `json:"name" gorm:"not null"`
+	Description  string    `json:"description" gorm:"type:text"`
+	URL          string    `json:"url"`          // 项目主页
+	RepoURL      string    `json:"repo_url"`     // 代码仓库
+	Technologies string    `json:"technologies"` // 使用的技术，逗号分隔
+	StartDate    string    `json:"start_date"`   // YYYY-MM
+	EndDate      string    `json:"end_date"`     // YYYY-MM，为空表示至今
+	SortOrder    int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// IsValidSkillLevel 检查技能熟练度是否有效，空值表示未设置
+func IsValidSkillLevel(level string) bool {
+	switch level {
+	case "", SkillLevelBeginner, SkillLevelIntermediate, SkillLevelAdvanced, SkillLevelExpert:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// TusUpload 断点续传（tus协议）的上传会话
+// 分块保存在存储的 tus/<id>/ 下，全部上传完成后合并为媒体文件
+type TusUpload struct {
+	ID          string     `json:"id" gorm:"primaryKey;size:32"`
+	OwnerID     uint       `json:"owner_id" gorm:"index;not null"`
+	Length      int64      `json:"length" gorm:"column:upload_length;not null"` // 文件总大小（Upload-Length）
+	Offset      int64      `json:"offset" gorm:"column:upload_offset;not null"` // 已接收的字节数（Upload-Offset）
+	FileName    string     `json:"file_name"`                                   // 元数据中的原始文件名
+	ContentType string     `json:"content_type"`                                // 元数据中声明的文件类型
+	Metadata    string     `json:"metadata" gorm:"type:text"`                   // 原始Upload-Metadata
+	Private     bool       `json:"private"`                                     // 元数据中的private，完成后登记为私有文件
+	MediaID     *uint      `json:"media_id"`                                    // 合并完成后登记的媒体记录
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"index"` // 过期后未完成的分块会被清理
+	LockToken   string     `json:"-" gorm:"size:32"`        // 正在接收分块的请求持有的锁
+	LockedUntil *time.Time `json:"-"`                       // 锁的过期时间，持有锁的进程异常退出后到期自动失效
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Media       *Media     `json:"media,omitempty" gorm:"foreignKey:MediaID;constraint:OnDelete:SET NULL"`
+}
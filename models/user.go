@@ -6,12 +6,31 @@ import (
 	"gorm.io/gorm"
 )
 
+// 用户角色
+const (
+	RoleAdmin       = "admin"       // 管理员
+	RoleContributor = "contributor" // 投稿者
+)
+
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Username  string         `json:"username" gorm:"uniqueIndex;not null"`
-	Email     string         `json:"email" gorm:"uniqueIndex;not null"`
-	Password  string         `json:"-" gorm:"not null"` // 不在JSON中返回密码
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"` // 软删除
-}
\ No newline at end of file
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Username     string         `json:"username" gorm:"uniqueIndex;not null"`
+	Email        string         `json:"email" gorm:"uniqueIndex;not null"`
+	Password     string         `json:"-" gorm:"not null"`                        // 不在JSON中返回密码
+	Role         string         `json:"role" gorm:"not null;default:contributor"` // admin, contributor
+	StorageQuota *int64         `json:"storage_quota"`                            // 存储配额（字节），为空时使用角色默认值，0表示不限
+	StorageUsed  int64          `json:"storage_used" gorm:"not null;default:0"`   // 已使用的存储空间（字节）
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"` // 软删除
+}
+
+// IsAdmin 是否为管理员
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// IsValidRole 检查角色是否有效
+func IsValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleContributor
+}
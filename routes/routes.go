@@ -3,6 +3,8 @@ package routes
 import (
 	"blog-server/controllers"
 	"blog-server/middleware"
+	"blog-server/utils"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,10 +16,15 @@ func SetupRoutes(r *gin.Engine) {
 	// 添加CORS中间件
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, HEAD, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, Tus-Resumable, Upload-Length, Upload-Metadata, Upload-Offset, X-Site-Key")
+		c.Header("Access-Control-Expose-Headers", "Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Metadata, Upload-Expires, Retry-After")
 
 		if c.Request.Method == "OPTIONS" {
+			// 断点续传客户端通过OPTIONS获取支持的协议版本和扩展
+			if strings.HasPrefix(c.Request.URL.Path, "/api/upload/tus") {
+				controllers.SetTusOptionsHeaders(c)
+			}
 			c.AbortWithStatus(204)
 			return
 		}
@@ -39,16 +46,61 @@ func SetupRoutes(r *gin.Engine) {
 		profile := api.Group("/profile")
 		{
 			profile.GET("", controllers.GetPublicProfile)
+			profile.GET("/export", controllers.ExportProfile)
 			// 需要认证的路由
+			profile.GET("/me", middleware.AuthMiddleware(), controllers.GetMyProfile)
 			profile.PUT("", middleware.AuthMiddleware(), controllers.UpdateProfile)
+
+			// 结构化简历信息（需要认证，操作自己的资料）
+			skills := profile.Group("/skills", middleware.AuthMiddleware())
+			{
+				skills.GET("", controllers.ListSkills)
+				skills.POST("", controllers.CreateSkill)
+				skills.PUT("/order", controllers.ReorderSkills)
+				skills.PUT("/:id", controllers.UpdateSkill)
+				skills.DELETE("/:id", controllers.DeleteSkill)
+			}
+
+			experiences := profile.Group("/experiences", middleware.AuthMiddleware())
+			{
+				experiences.GET("", controllers.ListExperiences)
+				experiences.POST("", controllers.CreateExperience)
+				experiences.PUT("/order", controllers.ReorderExperiences)
+				experiences.PUT("/:id", controllers.UpdateExperience)
+				experiences.DELETE("/:id", controllers.DeleteExperience)
+			}
+
+			educations := profile.Group("/educations", middleware.AuthMiddleware())
+			{
+				educations.GET("", controllers.ListEducations)
+				educations.POST("", controllers.CreateEducation)
+				educations.PUT("/order", controllers.ReorderEducations)
+				educations.PUT("/:id", controllers.UpdateEducation)
+				educations.DELETE("/:id", controllers.DeleteEducation)
+			}
+
+			projects := profile.Group("/projects", middleware.AuthMiddleware())
+			{
+				projects.GET("", controllers.ListProjects)
+				projects.POST("", controllers.CreateProject)
+				projects.PUT("/order", controllers.ReorderProjects)
+				projects.PUT("/:id", controllers.UpdateProject)
+				projects.DELETE("/:id", controllers.DeleteProject)
+			}
 		}
 
+		// 作者主页（无需认证）
+		api.GET("/authors/:username", controllers.GetAuthor)
+
+		// 响应式图片信息（无需认证）
+		api.GET("/media/responsive", controllers.GetResponsiveImages)
+
 		// 文章路由
 		articles := api.Group("/articles")
 		{
 			// 公共访问（无需认证）
 			articles.GET("", controllers.GetArticles)
-			articles.GET("/:id", controllers.GetArticle)
+			articles.GET("/:id", middleware.OptionalAuthMiddleware(), controllers.GetArticle) // 作者登录时可查看私有图片
 
 			// 需要认证的路由
 			articles.POST("", middleware.AuthMiddleware(), controllers.CreateArticle)
@@ -60,13 +112,48 @@ func SetupRoutes(r *gin.Engine) {
 		user := api.Group("/user").Use(middleware.AuthMiddleware())
 		{
 			user.GET("/profile", controllers.GetProfile)
+			user.GET("/storage", controllers.GetStorageUsage)
 		}
 
 		// 图片上传路由（需要认证）
 		upload := api.Group("/upload").Use(middleware.AuthMiddleware())
 		{
 			upload.POST("/presigned-url", controllers.GetPresignedURL)
+			upload.POST("/image", controllers.UploadImage)
+			upload.POST("/confirm", controllers.ConfirmUpload)
 			upload.DELETE("/image", controllers.DeleteImage)
+
+			// 断点续传（tus 1.0）
+			upload.POST("/tus", controllers.CreateTusUpload)
+			upload.HEAD("/tus/:id", controllers.GetTusUploadOffset)
+			upload.GET("/tus/:id", controllers.GetTusUpload)
+			upload.PATCH("/tus/:id", controllers.PatchTusUpload)
+			upload.DELETE("/tus/:id", controllers.DeleteTusUpload)
+		}
+
+		// 媒体库路由（需要认证）
+		media := api.Group("/media").Use(middleware.AuthMiddleware())
+		{
+			media.GET("", controllers.ListMedia)
+			media.GET("/transform-url", controllers.GetImageTransformURL)
+			media.GET("/:id", controllers.GetMedia)
+			media.POST("/:id/process", controllers.ProcessMediaVariants)
+			media.DELETE("/:id", controllers.DeleteMedia)
+		}
+
+		// 管理员路由
+		admin := api.Group("/admin").Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+		{
+			admin.GET("/users", controllers.ListUsers)
+			admin.PUT("/users/:id/role", controllers.UpdateUserRole)
+			admin.PUT("/users/:id/storage-quota", controllers.UpdateUserStorageQuota)
+			admin.PUT("/site-owner", controllers.SetSiteOwner)
+			admin.GET("/audit-logs", controllers.GetAuditLogs)
+			admin.GET("/audit-logs/export", controllers.ExportAuditLogs)
+			admin.GET("/orphan-scans", controllers.ListOrphanScans)
+			admin.POST("/orphan-scans", controllers.CreateOrphanScan)
+			admin.GET("/orphan-scans/:id", controllers.GetOrphanScan)
+			admin.POST("/orphan-scans/:id/purge", controllers.PurgeOrphanScan)
 		}
 
 		// 数据分析路由
@@ -74,6 +161,7 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			// 公共数据收集接口（无需认证）
 			analytics.POST("/track", controllers.Track)
+			analytics.POST("/batch", controllers.TrackBatch)
 			analytics.GET("/realtime", controllers.GetRealTimeStats)
 
 			// 需要认证的数据查询接口
@@ -84,16 +172,44 @@ func SetupRoutes(r *gin.Engine) {
 			// 详细数据分析接口（需认证）
 			analytics.GET("/events", middleware.AuthMiddleware(), controllers.GetTrackingEvents)
 			analytics.GET("/ip-stats", middleware.AuthMiddleware(), controllers.GetIPStats)
+			analytics.GET("/geo-stats", middleware.AuthMiddleware(), controllers.GetGeoStats)
 			analytics.GET("/user-agent-stats", middleware.AuthMiddleware(), controllers.GetUserAgentStats)
+			analytics.GET("/browser-stats", middleware.AuthMiddleware(), controllers.GetBrowserStats)
+			analytics.GET("/os-stats", middleware.AuthMiddleware(), controllers.GetOSStats)
+			analytics.GET("/device-stats", middleware.AuthMiddleware(), controllers.GetDeviceStats)
 			analytics.GET("/referer-stats", middleware.AuthMiddleware(), controllers.GetRefererStats)
 			analytics.GET("/session-stats", middleware.AuthMiddleware(), controllers.GetSessionStats)
 			analytics.GET("/event-type-stats", middleware.AuthMiddleware(), controllers.GetEventTypeStats)
+			analytics.GET("/event-properties", middleware.AuthMiddleware(), controllers.GetEventPropertyStats)
 			analytics.GET("/hourly-stats", middleware.AuthMiddleware(), controllers.GetHourlyStats)
 			analytics.GET("/path-analysis", middleware.AuthMiddleware(), controllers.GetPathAnalysis)
 			analytics.GET("/advanced-stats", middleware.AuthMiddleware(), controllers.GetAdvancedStats)
+
+			// 漏斗分析：保存的漏斗定义及其转化报告
+			analytics.GET("/funnels", middleware.AuthMiddleware(), controllers.ListFunnels)
+			analytics.POST("/funnels", middleware.AuthMiddleware(), controllers.CreateFunnel)
+			analytics.GET("/funnels/:id", middleware.AuthMiddleware(), controllers.GetFunnelReport)
+			analytics.PUT("/funnels/:id", middleware.AuthMiddleware(), controllers.UpdateFunnel)
+			analytics.DELETE("/funnels/:id", middleware.AuthMiddleware(), controllers.DeleteFunnel)
+
+			// 访客留存分析
+			analytics.GET("/cohorts", middleware.AuthMiddleware(), controllers.GetCohortStats)
+
+			// 实时数据推送（Server-Sent Events），令牌可通过token参数传递
+			analytics.GET("/stream", middleware.StreamAuthMiddleware(), controllers.StreamAnalytics)
+
+			// 数据收集接口拒绝的事件统计（管理员）
+			analytics.GET("/rejections", middleware.AuthMiddleware(), middleware.AdminMiddleware(), controllers.GetTrackingRejections)
 		}
 	}
 
+	// 本地存储文件访问（local、memory存储驱动）
+	r.GET(utils.StorageRoutePrefix+"*key", controllers.ServeStorageObject)
+	r.PUT(utils.StorageRoutePrefix+"*key", controllers.UploadStorageObject)
+
+	// 图片实时处理（需要签名）
+	r.GET(utils.ImageTransformRoutePrefix+"*key", controllers.ServeTransformedImage)
+
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"blog-server/models"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordAudit 记录业务审计日志
+// before/after 为修改前后的对象快照，创建时before传nil，删除时after传nil
+func RecordAudit(c *gin.Context, action, targetType string, targetID interface{}, before, after interface{}) {
+	entry := models.AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprintf("%v", targetID),
+		Before:     auditSnapshot(before),
+		After:      auditSnapshot(after),
+	}
+
+	if c != nil {
+		if uid, exists := c.Get("user_id"); exists {
+			if id, ok := uid.(uint); ok {
+				entry.ActorID = &id
+			}
+		}
+		entry.ActorName = c.GetString("username")
+		entry.IP = GetRealClientIP(c)
+	}
+
+	if err := models.DB.Create(&entry).Error; err != nil {
+		// 审计失败不影响业务流程，只记录日志
+		log.Printf("审计日志保存失败: %v", err)
+	}
+}
+
+// auditSnapshot 将对象序列化为JSON快照
+func auditSnapshot(v interface{}) *string {
+	if v == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("审计快照序列化失败: %v", err)
+		return nil
+	}
+
+	snapshot := string(data)
+	return &snapshot
+}
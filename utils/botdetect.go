@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"blog-server/config"
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 机器人的判定依据
+const (
+	BotReasonUserAgent = "user_agent" // User-Agent匹配爬虫、监控或脚本特征
+	BotReasonIPRange   = "ip_range"   // IP属于已知的爬虫网段
+	BotReasonRate      = "rate"       // 同一IP的请求频率过高
+	BotReasonBehavior  = "behavior"   // 会话只有大量页面访问，没有任何交互事件
+)
+
+// botUserAgentPattern 爬虫、监控服务和HTTP库的User-Agent特征
+var botUserAgentPattern = regexp.MustCompile(`(?i)bot\b|bot/|crawl|spider|slurp|scrape|curl/|wget/|python|go-http-client|java/|okhttp|httpclient|libwww|axios|node-fetch|headless|phantomjs|selenium|puppeteer|playwright|lighthouse|uptime|pingdom|statuscake|monitor|feedfetcher|preview`)
+
+// botIPRanges 从本地文件加载的爬虫网段
+var botIPRanges []*net.IPNet
+
+// InitBotDetector 加载已知爬虫的IP网段，未配置文件时只按User-Agent和请求频率判定
+// 文件每行一个CIDR或IP，#开头的行为注释
+func InitBotDetector() error {
+	path := config.AppConfig.BotIPRangesFile
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开爬虫IP列表失败: %v", err)
+	}
+	defer file.Close()
+
+	var ranges []*net.IPNet
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cidr := line
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Printf("爬虫IP列表第%d行格式错误，已跳过: %s", lineNo, line)
+			continue
+		}
+		ranges = append(ranges, network)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取爬虫IP列表失败: %v", err)
+	}
+
+	botIPRanges = ranges
+	log.Printf("已加载 %d 个爬虫IP网段", len(ranges))
+	return nil
+}
+
+// ClassifyBot 在收集数据时判定请求是否来自机器人，返回判定依据
+// events为本次请求包含的事件数，会话行为的判定在转存时进行，见markBehavioralBots
+func ClassifyBot(ctx context.Context, ipAddress, userAgent string, uaInfo UserAgentInfo, events int) (bool, string) {
+	if uaInfo.DeviceType == DeviceBot || botUserAgentPattern.MatchString(userAgent) {
+		return true, BotReasonUserAgent
+	}
+
+	if ip := net.ParseIP(ipAddress); ip != nil {
+		for _, network := range botIPRanges {
+			if network.Contains(ip) {
+				return true, BotReasonIPRange
+			}
+		}
+	}
+
+	if exceedsBotRate(ctx, ipAddress, events) {
+		return true, BotReasonRate
+	}
+	return false, ""
+}
+
+// exceedsBotRate 按分钟统计同一IP的事件数，超过阈值视为机器人
+func exceedsBotRate(ctx context.Context, ipAddress string, events int) bool {
+	limit := config.AppConfig.BotRateLimit
+	if limit <= 0 || RedisClient == nil {
+		return false
+	}
+
+	ipKey := IPCounterKey(ctx, "bot_rate", ipAddress)
+	if ipKey == "" {
+		return false
+	}
+	key := ipKey + ":" + time.Now().Format("200601021504")
+	count, err := RedisClient.IncrBy(ctx, key, int64(events)).Result()
+	if err != nil {
+		return false
+	}
+	if count == int64(events) {
+		RedisClient.Expire(ctx, key, 2*time.Minute)
+	}
+	return count > limit
+}
+
+// markBehavioralBots 标记只有大量页面访问、没有任何交互事件的会话
+// 正常读者在浏览多个页面时总会产生文章点击等由脚本上报的事件
+func markBehavioralBots(trackingDataList []TrackingData) {
+	threshold := config.AppConfig.BotSessionPageViews
+	if threshold <= 0 {
+		return
+	}
+
+	type sessionActivity struct {
+		pageViews   int64
+		interactive bool
+	}
+	sessions := make(map[string]*sessionActivity)
+	for _, data := range trackingDataList {
+		activity := sessions[data.SessionID]
+		if activity == nil {
+			activity = &sessionActivity{}
+			sessions[data.SessionID] = activity
+		}
+		if data.EventType == "page_view" {
+			activity.pageViews++
+		} else {
+			activity.interactive = true
+		}
+	}
+
+	for i := range trackingDataList {
+		data := &trackingDataList[i]
+		activity := sessions[data.SessionID]
+		if !data.IsBot && !activity.interactive && activity.pageViews >= threshold {
+			data.IsBot = true
+			data.BotReason = BotReasonBehavior
+		}
+	}
+}
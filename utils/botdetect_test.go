@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"blog-server/config"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// chrome 普通桌面浏览器的User-Agent
+const chrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+// setupTestRedis 使用内存Redis替换RedisClient，测试结束后恢复
+func setupTestRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	server := miniredis.RunT(t)
+	previous := RedisClient
+	RedisClient = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		RedisClient.Close()
+		RedisClient = previous
+	})
+	return server
+}
+
+func TestClassifyBot(t *testing.T) {
+	setupTestRedis(t)
+	config.AppConfig = &config.Config{BotRateLimit: 10}
+
+	_, network, _ := net.ParseCIDR("66.249.64.0/19")
+	botIPRanges = []*net.IPNet{network}
+	t.Cleanup(func() { botIPRanges = nil })
+
+	tests := []struct {
+		name       string
+		ip         string
+		userAgent  string
+		events     int
+		wantBot    bool
+		wantReason string
+	}{
+		{"普通浏览器", "203.0.113.1", chrome, 1, false, ""},
+		{"搜索引擎爬虫", "203.0.113.2", "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", 1, true, BotReasonUserAgent},
+		{"curl", "203.0.113.3", "curl/8.4.0", 1, true, BotReasonUserAgent},
+		{"Python脚本", "203.0.113.4", "python-requests/2.31.0", 1, true, BotReasonUserAgent},
+		{"无头浏览器", "203.0.113.5", "Mozilla/5.0 HeadlessChrome/120.0.0.0 Safari/537.36", 1, true, BotReasonUserAgent},
+		{"空User-Agent", "203.0.113.6", "", 1, true, BotReasonUserAgent},
+		{"爬虫网段", "66.249.66.1", chrome, 1, true, BotReasonIPRange},
+		{"请求频率过高", "203.0.113.7", chrome, 11, true, BotReasonRate},
+		{"正好达到频率上限", "203.0.113.8", chrome, 10, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isBot, reason := ClassifyBot(context.Background(), tt.ip, tt.userAgent, ParseUserAgent(tt.userAgent), tt.events)
+			if isBot != tt.wantBot || reason != tt.wantReason {
+				t.Errorf("ClassifyBot() = (%v, %q), want (%v, %q)", isBot, reason, tt.wantBot, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestClassifyBotRateAccumulates(t *testing.T) {
+	server := setupTestRedis(t)
+	config.AppConfig = &config.Config{BotRateLimit: 10}
+
+	uaInfo := ParseUserAgent(chrome)
+	ctx := context.Background()
+
+	// 同一分钟内的多次请求累计计数
+	for i := 0; i < 2; i++ {
+		if isBot, _ := ClassifyBot(ctx, "203.0.113.9", chrome, uaInfo, 5); isBot {
+			t.Fatalf("第%d次请求不应判定为机器人", i+1)
+		}
+	}
+	if isBot, reason := ClassifyBot(ctx, "203.0.113.9", chrome, uaInfo, 1); !isBot || reason != BotReasonRate {
+		t.Errorf("超过上限后 ClassifyBot() = (%v, %q), want (true, %q)", isBot, reason, BotReasonRate)
+	}
+
+	// 计数键不包含原始IP
+	for _, key := range server.Keys() {
+		if strings.Contains(key, "203.0.113.9") {
+			t.Errorf("计数键包含原始IP: %s", key)
+		}
+	}
+}
+
+func TestClassifyBotWithoutRedis(t *testing.T) {
+	previous := RedisClient
+	RedisClient = nil
+	t.Cleanup(func() { RedisClient = previous })
+	config.AppConfig = &config.Config{BotRateLimit: 1}
+
+	if isBot, reason := ClassifyBot(context.Background(), "203.0.113.10", chrome, ParseUserAgent(chrome), 100); isBot {
+		t.Errorf("未连接Redis时不应按频率判定，got reason %q", reason)
+	}
+}
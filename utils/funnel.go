@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"blog-server/models"
+	"strings"
+	"time"
+)
+
+// FunnelStepResult 漏斗中单个步骤的转化情况，比例均在0到1之间
+type FunnelStepResult struct {
+	Step               int     `json:"step"`
+	Name               string  `json:"name"`
+	Path               string  `json:"path,omitempty"`
+	EventType          string  `json:"event_type,omitempty"`
+	Sessions           int64   `json:"sessions"`             // 到达该步骤的会话数
+	ConversionRate     float64 `json:"conversion_rate"`      // 相对第一步的转化率
+	StepConversionRate float64 `json:"step_conversion_rate"` // 相对上一步的转化率
+	DropOff            int64   `json:"drop_off"`             // 到达上一步但没有到达该步骤的会话数
+	DropOffRate        float64 `json:"drop_off_rate"`
+}
+
+// AnalyzeFunnel 按会话统计漏斗各步骤的到达数
+// 第一步需发生在[start, end)内，后续步骤需按顺序发生在第一步之后的时间窗口内，可以超出end
+func AnalyzeFunnel(funnel models.Funnel, start, end time.Time, includeBots bool) ([]FunnelStepResult, error) {
+	steps := funnel.Steps
+	if len(steps) == 0 {
+		return []FunnelStepResult{}, nil
+	}
+	window := time.Duration(funnel.WindowMinutes) * time.Minute
+
+	// 只读取命中任一步骤的事件，按会话和时间排序后逐个会话计算
+	stepFilter := models.DB.Where("1 = 0")
+	for _, step := range steps {
+		stepFilter = stepFilter.Or(funnelStepCondition(step))
+	}
+	query := models.DB.Model(&models.TrackingEvent{}).
+		Select("session_id, timestamp, path, event_type").
+		Where("timestamp >= ? AND timestamp < ?", start, end.Add(window)).
+		Where(stepFilter)
+	if !includeBots {
+		query = query.Where("is_bot = ?", false)
+	}
+	rows, err := query.Order("session_id, timestamp, id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]int64, len(steps))
+	// reached[i] 到达第i步的转化链中最晚的开始时间，零值表示还没有到达
+	reached := make([]time.Time, len(steps))
+	currentSession := ""
+	finishSession := func() {
+		for i := range reached {
+			if !reached[i].IsZero() {
+				counts[i]++
+			}
+			reached[i] = time.Time{}
+		}
+	}
+
+	for rows.Next() {
+		var sessionID, path, eventType string
+		var timestamp time.Time
+		if err := rows.Scan(&sessionID, &timestamp, &path, &eventType); err != nil {
+			return nil, err
+		}
+		if sessionID != currentSession {
+			finishSession()
+			currentSession = sessionID
+		}
+
+		// 从后往前处理，同一个事件不会同时满足相邻的两个步骤
+		for i := len(steps) - 1; i >= 0; i-- {
+			if !funnelStepMatches(steps[i], path, eventType) {
+				continue
+			}
+			if i == 0 {
+				if timestamp.Before(end) {
+					reached[0] = timestamp
+				}
+				continue
+			}
+			chainStart := reached[i-1]
+			if !chainStart.IsZero() && timestamp.Sub(chainStart) <= window && chainStart.After(reached[i]) {
+				reached[i] = chainStart
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	finishSession()
+
+	results := make([]FunnelStepResult, len(steps))
+	for i, step := range steps {
+		result := FunnelStepResult{
+			Step:      i + 1,
+			Name:      step.Name,
+			Path:      step.Path,
+			EventType: step.EventType,
+			Sessions:  counts[i],
+		}
+		if counts[0] > 0 {
+			result.ConversionRate = float64(counts[i]) / float64(counts[0])
+		}
+		if i == 0 {
+			result.StepConversionRate = 1
+		} else if counts[i-1] > 0 {
+			result.DropOff = counts[i-1] - counts[i]
+			result.StepConversionRate = float64(counts[i]) / float64(counts[i-1])
+			result.DropOffRate = float64(result.DropOff) / float64(counts[i-1])
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// funnelStepCondition 生成步骤的查询条件，与funnelStepMatches的规则一致
+func funnelStepCondition(step models.FunnelStep) interface{} {
+	condition := models.DB.Where("1 = 1")
+	if prefix, ok := strings.CutSuffix(step.Path, "*"); ok {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+		condition = condition.Where("path LIKE ?", escaped+"%")
+	} else if step.Path != "" {
+		condition = condition.Where("path = ?", step.Path)
+	}
+	if step.EventType != "" {
+		condition = condition.Where("event_type = ?", step.EventType)
+	}
+	return condition
+}
+
+// funnelStepMatches 判断事件是否命中步骤
+func funnelStepMatches(step models.FunnelStep, path, eventType string) bool {
+	if step.EventType != "" && step.EventType != eventType {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(step.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return step.Path == "" || step.Path == path
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"blog-server/models"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testDBCount int
+
+// setupTestDB 使用内存SQLite替换models.DB，测试结束后恢复
+func setupTestDB(t *testing.T, tables ...interface{}) {
+	t.Helper()
+	// 每个测试使用独立的共享缓存内存库，连接池中的多个连接看到相同的数据
+	testDBCount++
+	dsn := fmt.Sprintf("file:utils_test_%d?mode=memory&cache=shared", testDBCount)
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("创建测试表失败: %v", err)
+	}
+
+	previous := models.DB
+	models.DB = db
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		models.DB = previous
+	})
+}
+
+func TestAnalyzeFunnel(t *testing.T) {
+	setupTestDB(t, &models.TrackingEvent{})
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	events := []models.TrackingEvent{
+		// s1 完成全部步骤
+		{SessionID: "s1", Timestamp: at(10), Path: "/", EventType: "page_view"},
+		{SessionID: "s1", Timestamp: at(11), Path: "/articles/go", EventType: "page_view"},
+		{SessionID: "s1", Timestamp: at(12), Path: "/articles/go", EventType: "signup"},
+		// s2 只到第二步
+		{SessionID: "s2", Timestamp: at(20), Path: "/", EventType: "page_view"},
+		{SessionID: "s2", Timestamp: at(21), Path: "/articles/rust", EventType: "page_view"},
+		// s3 第二步超出时间窗口
+		{SessionID: "s3", Timestamp: at(30), Path: "/", EventType: "page_view"},
+		{SessionID: "s3", Timestamp: at(100), Path: "/articles/go", EventType: "page_view"},
+		// s4 顺序颠倒，只算第一步
+		{SessionID: "s4", Timestamp: at(40), Path: "/articles/go", EventType: "page_view"},
+		{SessionID: "s4", Timestamp: at(41), Path: "/", EventType: "page_view"},
+		// s5 第一步在时间范围之外
+		{SessionID: "s5", Timestamp: start.Add(-time.Minute), Path: "/", EventType: "page_view"},
+		{SessionID: "s5", Timestamp: at(1), Path: "/articles/go", EventType: "page_view"},
+		// s6 机器人会话
+		{SessionID: "s6", Timestamp: at(50), Path: "/", EventType: "page_view", IsBot: true},
+		{SessionID: "s6", Timestamp: at(51), Path: "/articles/go", EventType: "page_view", IsBot: true},
+		// s7 第一步在end之前，后续步骤在end之后但仍在窗口内
+		{SessionID: "s7", Timestamp: end.Add(-time.Minute), Path: "/", EventType: "page_view"},
+		{SessionID: "s7", Timestamp: end.Add(time.Minute), Path: "/articles/go", EventType: "page_view"},
+		// s8 第一次进入后超时，第二次进入后在窗口内完成
+		{SessionID: "s8", Timestamp: at(60), Path: "/", EventType: "page_view"},
+		{SessionID: "s8", Timestamp: at(120), Path: "/", EventType: "page_view"},
+		{SessionID: "s8", Timestamp: at(125), Path: "/articles/go", EventType: "page_view"},
+		{SessionID: "s8", Timestamp: at(126), Path: "/articles/go", EventType: "signup"},
+	}
+	for i := range events {
+		events[i].IPAddress = "203.0.113.0"
+		events[i].UserAgent = "hash"
+	}
+	if err := models.DB.Create(&events).Error; err != nil {
+		t.Fatalf("写入测试事件失败: %v", err)
+	}
+
+	funnel := models.Funnel{
+		WindowMinutes: 30,
+		Steps: []models.FunnelStep{
+			{Name: "首页", Path: "/", EventType: "page_view"},
+			{Name: "文章", Path: "/articles/*", EventType: "page_view"},
+			{Name: "注册", EventType: "signup"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		includeBots bool
+		sessions    []int64
+		dropOff     []int64
+	}{
+		{"排除机器人", false, []int64{6, 4, 2}, []int64{0, 2, 2}},
+		{"包含机器人", true, []int64{7, 5, 2}, []int64{0, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := AnalyzeFunnel(funnel, start, end, tt.includeBots)
+			if err != nil {
+				t.Fatalf("AnalyzeFunnel() error = %v", err)
+			}
+			if len(results) != len(funnel.Steps) {
+				t.Fatalf("返回%d个步骤, want %d", len(results), len(funnel.Steps))
+			}
+			for i, result := range results {
+				if result.Step != i+1 || result.Name != funnel.Steps[i].Name {
+					t.Errorf("第%d步 = %d %q", i+1, result.Step, result.Name)
+				}
+				if result.Sessions != tt.sessions[i] {
+					t.Errorf("第%d步会话数 = %d, want %d", i+1, result.Sessions, tt.sessions[i])
+				}
+				if result.DropOff != tt.dropOff[i] {
+					t.Errorf("第%d步流失数 = %d, want %d", i+1, result.DropOff, tt.dropOff[i])
+				}
+			}
+
+			if results[0].ConversionRate != 1 || results[0].StepConversionRate != 1 {
+				t.Errorf("第一步转化率 = %v/%v, want 1/1", results[0].ConversionRate, results[0].StepConversionRate)
+			}
+			wantRate := float64(tt.sessions[2]) / float64(tt.sessions[1])
+			if results[2].StepConversionRate != wantRate || results[2].DropOffRate != 1-wantRate {
+				t.Errorf("第三步转化率 = %v, 流失率 = %v", results[2].StepConversionRate, results[2].DropOffRate)
+			}
+		})
+	}
+}
+
+func TestAnalyzeFunnelEmpty(t *testing.T) {
+	setupTestDB(t, &models.TrackingEvent{})
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	funnel := models.Funnel{
+		WindowMinutes: 30,
+		Steps:         []models.FunnelStep{{Path: "/"}, {Path: "/about"}},
+	}
+
+	results, err := AnalyzeFunnel(funnel, start, start.Add(24*time.Hour), false)
+	if err != nil {
+		t.Fatalf("AnalyzeFunnel() error = %v", err)
+	}
+	for _, result := range results {
+		if result.Sessions != 0 || result.ConversionRate != 0 || result.DropOffRate != 0 {
+			t.Errorf("没有事件时第%d步 = %+v", result.Step, result)
+		}
+	}
+
+	results, err = AnalyzeFunnel(models.Funnel{}, start, start.Add(24*time.Hour), false)
+	if err != nil || len(results) != 0 {
+		t.Errorf("没有步骤时 AnalyzeFunnel() = %v, %v", results, err)
+	}
+}
+
+func TestFunnelStepMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		step      models.FunnelStep
+		path      string
+		eventType string
+		want      bool
+	}{
+		{"路径完全相同", models.FunnelStep{Path: "/about"}, "/about", "page_view", true},
+		{"路径不同", models.FunnelStep{Path: "/about"}, "/about/me", "page_view", false},
+		{"前缀匹配", models.FunnelStep{Path: "/articles/*"}, "/articles/go", "page_view", true},
+		{"前缀不匹配", models.FunnelStep{Path: "/articles/*"}, "/tags/go", "page_view", false},
+		{"只限事件类型", models.FunnelStep{EventType: "signup"}, "/any", "signup", true},
+		{"事件类型不同", models.FunnelStep{Path: "/", EventType: "signup"}, "/", "page_view", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := funnelStepMatches(tt.step, tt.path, tt.eventType); got != tt.want {
+				t.Errorf("funnelStepMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
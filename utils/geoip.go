@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"blog-server/config"
+	"container/list"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoLocation IP解析出的地理位置，无法解析时为空
+type GeoLocation struct {
+	Country string `json:"country"`
+	City    string `json:"city"`
+}
+
+var (
+	geoIPReader *geoip2.Reader
+	geoIPCache  *geoLRU
+)
+
+// InitGeoIP 打开本地的GeoLite2-City数据库，未配置路径时不启用
+func InitGeoIP() error {
+	path := config.AppConfig.GeoIPDatabasePath
+	if path == "" {
+		return nil
+	}
+
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开GeoIP数据库失败: %v", err)
+	}
+	geoIPReader = reader
+	geoIPCache = newGeoLRU(int(config.AppConfig.GeoIPCacheSize))
+
+	log.Printf("GeoIP数据库加载成功: %s", reader.Metadata().DatabaseType)
+	return nil
+}
+
+// LookupGeo 解析IP的国家和城市，未启用GeoIP或IP无效、为内网地址时返回空
+func LookupGeo(ipAddress string) GeoLocation {
+	if geoIPReader == nil {
+		return GeoLocation{}
+	}
+	if location, ok := geoIPCache.get(ipAddress); ok {
+		return location
+	}
+
+	var location GeoLocation
+	if ip := net.ParseIP(ipAddress); ip != nil && !ip.IsPrivate() && !ip.IsLoopback() {
+		if record, err := geoIPReader.City(ip); err == nil {
+			location.Country = localizedName(record.Country.Names)
+			location.City = localizedName(record.City.Names)
+		}
+	}
+
+	geoIPCache.add(ipAddress, location)
+	return location
+}
+
+// localizedName 按配置的语言取名称，缺少时使用英文
+func localizedName(names map[string]string) string {
+	if name := names[config.AppConfig.GeoIPLanguage]; name != "" {
+		return name
+	}
+	return names["en"]
+}
+
+// geoLRU 并发安全的定长LRU缓存
+type geoLRU struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+type geoLRUEntry struct {
+	ip       string
+	location GeoLocation
+}
+
+func newGeoLRU(capacity int) *geoLRU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &geoLRU{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *geoLRU) get(ip string) (GeoLocation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[ip]
+	if !ok {
+		return GeoLocation{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*geoLRUEntry).location, true
+}
+
+func (c *geoLRU) add(ip string, location GeoLocation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[ip]; ok {
+		element.Value.(*geoLRUEntry).location = location
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[ip] = c.order.PushFront(&geoLRUEntry{ip: ip, location: location})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*geoLRUEntry).ip)
+	}
+}
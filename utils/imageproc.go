@@ -0,0 +1,437 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+)
+
+// ImageVariantWidths 生成的响应式图片宽度
+var ImageVariantWidths = []int{320, 768, 1280}
+
+// maxImagePixels 允许处理的最大像素数，防止解压炸弹耗尽内存
+const maxImagePixels = 50_000_000
+
+// 重新编码JPEG时使用的质量
+const (
+	jpegOriginalQuality = 90
+	jpegVariantQuality  = 82
+)
+
+// ErrImageNotProcessable 图片不需要或无法生成响应式版本（如GIF动图、像素过多）
+var ErrImageNotProcessable = errors.New("图片不支持处理")
+
+// EncodedImage 编码后的图片版本
+type EncodedImage struct {
+	Suffix   string // 对象键后缀，如 _w320，全尺寸版本为空
+	Ext      string // 扩展名，如 .webp
+	MimeType string
+	Width    int
+	Height   int
+	Data     []byte
+}
+
+// ProcessedImage 图片处理结果
+type ProcessedImage struct {
+	// Original 去除元数据后的原图，为nil表示原图无需修改
+	Original []byte
+	Width    int
+	Height   int
+	Variants []EncodedImage
+}
+
+// ProcessImage 去除原图的EXIF/GPS等元数据，并生成不同宽度的缩放版本和WebP版本
+// JPEG带有方向信息时按方向旋转后重新编码，避免去除EXIF后图片方向错误
+func ProcessImage(data []byte, mimeType string) (*ProcessedImage, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解析图片失败: %v", err)
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrImageNotProcessable
+	}
+
+	if mimeType == "image/gif" {
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("解析图片失败: %v", err)
+		}
+		// 动图缩放后会丢失动画，保留原图
+		if len(g.Image) > 1 {
+			return nil, ErrImageNotProcessable
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解析图片失败: %v", err)
+	}
+
+	result := &ProcessedImage{}
+
+	switch mimeType {
+	case "image/jpeg":
+		if orientation := jpegOrientation(data); orientation > 1 {
+			img = applyOrientation(img, orientation)
+			if result.Original, err = encodeImage(img, "image/jpeg"); err != nil {
+				return nil, err
+			}
+		} else if result.Original, err = stripJPEGMetadata(data); err != nil {
+			// 结构异常的文件直接重新编码
+			if result.Original, err = encodeImage(img, "image/jpeg"); err != nil {
+				return nil, err
+			}
+		}
+	case "image/png":
+		result.Original, err = stripPNGMetadata(data)
+	case "image/webp":
+		result.Original, err = stripWebPMetadata(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.Original != nil && bytes.Equal(result.Original, data) {
+		result.Original = nil
+	}
+
+	bounds := img.Bounds()
+	result.Width, result.Height = bounds.Dx(), bounds.Dy()
+
+	// 缩放版本保持原格式（GIF使用PNG），WebP原图只生成WebP版本
+	variantType, variantExt := mimeType, extensionForType(mimeType)
+	if mimeType == "image/gif" {
+		variantType, variantExt = "image/png", ".png"
+	}
+
+	for _, width := range ImageVariantWidths {
+		if width >= result.Width {
+			break
+		}
+		resized := resizeImage(img, width)
+		suffix := fmt.Sprintf("_w%d", width)
+
+		fallbackSize := -1
+		if variantType != "image/webp" {
+			variant, err := newEncodedImage(resized, variantType, suffix, variantExt)
+			if err != nil {
+				return nil, err
+			}
+			result.Variants = append(result.Variants, variant)
+			fallbackSize = len(variant.Data)
+		}
+
+		if err := result.addWebP(resized, suffix, fallbackSize); err != nil {
+			return nil, err
+		}
+	}
+
+	// 全尺寸WebP版本
+	if mimeType != "image/webp" {
+		originalSize := len(data)
+		if result.Original != nil {
+			originalSize = len(result.Original)
+		}
+		if err := result.addWebP(img, "", originalSize); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// addWebP 添加WebP版本
+// 纯Go编码器只支持无损压缩，照片类图片往往比JPEG更大，此时不保留WebP版本
+func (p *ProcessedImage) addWebP(img image.Image, suffix string, fallbackSize int) error {
+	variant, err := newEncodedImage(img, "image/webp", suffix, ".webp")
+	if err != nil {
+		return err
+	}
+	if fallbackSize >= 0 && len(variant.Data) >= fallbackSize {
+		return nil
+	}
+	p.Variants = append(p.Variants, variant)
+	return nil
+}
+
+func newEncodedImage(img image.Image, mimeType, suffix, ext string) (EncodedImage, error) {
+	data, err := encodeImage(img, mimeType)
+	if err != nil {
+		return EncodedImage{}, err
+	}
+	bounds := img.Bounds()
+	return EncodedImage{
+		Suffix:   suffix,
+		Ext:      ext,
+		MimeType: mimeType,
+		Width:    bounds.Dx(),
+		Height:   bounds.Dy(),
+		Data:     data,
+	}, nil
+}
+
+// encodeImage 按类型编码图片，WebP使用纯Go的无损编码
+func encodeImage(img image.Image, mimeType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch mimeType {
+	case "image/jpeg":
+		quality := jpegVariantQuality
+		if img.Bounds().Dx() > ImageVariantWidths[len(ImageVariantWidths)-1] {
+			quality = jpegOriginalQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	case "image/png":
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+	case "image/webp":
+		err = nativewebp.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("不支持的编码类型: %s", mimeType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("编码图片失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func extensionForType(mimeType string) string {
+	if extensions, ok := allowedImageTypes[mimeType]; ok {
+		return extensions[0]
+	}
+	return ""
+}
+
+// resizeImage 按宽度等比缩放
+func resizeImage(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// applyOrientation 按EXIF方向（1-8）旋转或翻转图片
+func applyOrientation(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转180度
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转90度
+				dx, dy = h-1-y, x
+			case 7: // 沿右上-左下对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转90度
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			si := src.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation 读取JPEG中EXIF的方向标记，不存在时返回1
+func jpegOrientation(data []byte) int {
+	for _, segment := range jpegSegments(data) {
+		if segment.marker != 0xE1 || !bytes.HasPrefix(segment.payload, []byte("Exif\x00\x00")) {
+			continue
+		}
+		tiff := segment.payload[6:]
+		if len(tiff) < 8 {
+			return 1
+		}
+
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return 1
+		}
+
+		ifd := int(order.Uint32(tiff[4:8]))
+		if ifd+2 > len(tiff) {
+			return 1
+		}
+		count := int(order.Uint16(tiff[ifd:]))
+		for i := 0; i < count; i++ {
+			entry := ifd + 2 + i*12
+			if entry+12 > len(tiff) {
+				return 1
+			}
+			if order.Uint16(tiff[entry:]) == 0x0112 {
+				orientation := int(order.Uint16(tiff[entry+8:]))
+				if orientation < 1 || orientation > 8 {
+					return 1
+				}
+				return orientation
+			}
+		}
+		return 1
+	}
+	return 1
+}
+
+type jpegSegment struct {
+	marker  byte
+	start   int // 段起始位置（包含0xFF标记）
+	end     int
+	payload []byte
+}
+
+// jpegSegments 解析SOS之前的JPEG段，结构异常时返回nil
+func jpegSegments(data []byte) []jpegSegment {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	var segments []jpegSegment
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+		segments = append(segments, jpegSegment{
+			marker:  marker,
+			start:   pos,
+			end:     end,
+			payload: data[pos+4 : end],
+		})
+		if marker == 0xDA {
+			break
+		}
+		pos = end
+	}
+	return segments
+}
+
+// stripJPEGMetadata 无损去除JPEG中的EXIF、XMP、IPTC和注释段
+// 保留APP0（JFIF）、APP2（ICC色彩配置）和APP14（Adobe色彩变换）
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	segments := jpegSegments(data)
+	if len(segments) == 0 || segments[len(segments)-1].marker != 0xDA {
+		return nil, errors.New("无效的JPEG文件")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	for _, segment := range segments {
+		switch {
+		case segment.marker == 0xFE: // COM
+			continue
+		case segment.marker >= 0xE1 && segment.marker <= 0xEF && segment.marker != 0xE2 && segment.marker != 0xEE:
+			continue
+		}
+		if segment.marker == 0xDA {
+			// SOS之后为压缩数据，原样保留
+			out = append(out, data[segment.start:]...)
+			break
+		}
+		out = append(out, data[segment.start:segment.end]...)
+	}
+	return out, nil
+}
+
+// stripPNGMetadata 去除PNG中的EXIF、文本和时间块
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if len(data) < len(signature) || string(data[:len(signature)]) != signature {
+		return nil, errors.New("无效的PNG文件")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, signature...)
+	pos := len(signature)
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errors.New("无效的PNG文件")
+		}
+		switch string(data[pos+4 : pos+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+	return out, nil
+}
+
+// stripWebPMetadata 去除WebP中的EXIF和XMP块，并清除VP8X中的对应标记
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("无效的WebP文件")
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	pos := 12
+	for pos+8 <= len(data) {
+		fourCC := string(data[pos : pos+4])
+		length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + length + length%2
+		if end > len(data) {
+			return nil, errors.New("无效的WebP文件")
+		}
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, data[pos:end]...)
+			if length > 0 {
+				out[start+8] &^= 0x08 | 0x04 // EXIF、XMP标记
+			}
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
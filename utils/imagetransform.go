@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"blog-server/config"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// ImageTransformRoutePrefix 图片实时处理的访问路由前缀
+const ImageTransformRoutePrefix = "/img/"
+
+// imageCachePrefix 处理结果的缓存对象前缀，按原图分目录，原图删除时一并清理
+const imageCachePrefix = "cache/img/"
+
+// maxTransformDimension 处理结果的最大宽高
+const maxTransformDimension = 4096
+
+// 缩放方式
+const (
+	ImageFitContain = "contain" // 等比缩放到宽高范围内（默认）
+	ImageFitCover   = "cover"   // 等比缩放后居中裁剪为指定宽高
+	ImageFitFill    = "fill"    // 拉伸为指定宽高
+)
+
+// imageTransformFormats 支持输出的格式
+var imageTransformFormats = map[string]string{
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+	"webp": "image/webp",
+}
+
+// imageTransformSlots 限制同时处理的图片数量
+var imageTransformSlots = make(chan struct{}, 4)
+
+// ErrInvalidImageSignature 图片处理URL的签名无效
+var ErrInvalidImageSignature = errors.New("图片处理URL签名无效")
+
+// ImageTransformOptions 图片处理参数
+type ImageTransformOptions struct {
+	Width  int
+	Height int
+	Fit    string
+	Format string // 输出格式，为空时与原图相同（GIF输出PNG）
+}
+
+// ParseImageTransformOptions 解析并校验处理参数
+func ParseImageTransformOptions(width, height, fit, format string) (ImageTransformOptions, error) {
+	var opts ImageTransformOptions
+	var err error
+
+	if width != "" {
+		if opts.Width, err = strconv.Atoi(width); err != nil || opts.Width < 1 || opts.Width > maxTransformDimension {
+			return opts, fmt.Errorf("w必须在1到%d之间", maxTransformDimension)
+		}
+	}
+	if height != "" {
+		if opts.Height, err = strconv.Atoi(height); err != nil || opts.Height < 1 || opts.Height > maxTransformDimension {
+			return opts, fmt.Errorf("h必须在1到%d之间", maxTransformDimension)
+		}
+	}
+
+	opts.Fit = strings.ToLower(fit)
+	switch opts.Fit {
+	case "":
+		opts.Fit = ImageFitContain
+	case ImageFitContain, ImageFitCover, ImageFitFill:
+	default:
+		return opts, fmt.Errorf("不支持的fit: %s", fit)
+	}
+
+	opts.Format = strings.ToLower(format)
+	if opts.Format == "jpg" {
+		opts.Format = "jpeg"
+	}
+	if _, ok := imageTransformFormats[opts.Format]; opts.Format != "" && !ok {
+		return opts, fmt.Errorf("不支持的format: %s", format)
+	}
+
+	return opts, nil
+}
+
+// canonical 参与签名和缓存的规范化参数
+func (o ImageTransformOptions) canonical() string {
+	return fmt.Sprintf("w=%d&h=%d&fit=%s&format=%s", o.Width, o.Height, o.Fit, o.Format)
+}
+
+// query 处理URL中的查询参数，省略默认值
+func (o ImageTransformOptions) query() url.Values {
+	query := url.Values{}
+	if o.Width > 0 {
+		query.Set("w", strconv.Itoa(o.Width))
+	}
+	if o.Height > 0 {
+		query.Set("h", strconv.Itoa(o.Height))
+	}
+	if o.Fit != ImageFitContain {
+		query.Set("fit", o.Fit)
+	}
+	if o.Format != "" {
+		query.Set("format", o.Format)
+	}
+	return query
+}
+
+// imageTransformSigner 图片处理URL使用存储的签名密钥
+func imageTransformSigner() urlSigner {
+	return newURLSigner(config.AppConfig.ImageBaseURL, config.AppConfig.StorageSigningKey)
+}
+
+// SignImageTransformURL 生成带签名的图片处理URL，签名不过期，结果可被长期缓存
+func SignImageTransformURL(key string, opts ImageTransformOptions) string {
+	signer := imageTransformSigner()
+	query := opts.query()
+	query.Set("s", signer.sign("IMG", key, opts.canonical()))
+	return signer.baseURL + ImageTransformRoutePrefix + escapeKeyPath(key) + "?" + query.Encode()
+}
+
+// VerifyImageTransformSignature 校验图片处理URL的签名
+func VerifyImageTransformSignature(key string, opts ImageTransformOptions, signature string) error {
+	expected := imageTransformSigner().sign("IMG", key, opts.canonical())
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidImageSignature
+	}
+	return nil
+}
+
+// ImageTransformETag 处理结果的ETag，同一原图和参数的结果不变
+func ImageTransformETag(key string, opts ImageTransformOptions) string {
+	return `"` + imageTransformHash(key, opts) + `"`
+}
+
+func imageTransformHash(key string, opts ImageTransformOptions) string {
+	sum := sha256.Sum256([]byte(key + "\n" + opts.canonical()))
+	return hex.EncodeToString(sum[:16])
+}
+
+// imageCacheDir 原图对应的缓存目录
+func imageCacheDir(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return imageCachePrefix + hex.EncodeToString(sum[:16]) + "/"
+}
+
+// TransformImage 读取原图并按参数处理，优先返回缓存的结果
+func TransformImage(key string, opts ImageTransformOptions) ([]byte, string, error) {
+	cacheKey := imageCacheDir(key) + imageTransformHash(key, opts)
+	if reader, _, err := Storage.Get(cacheKey); err == nil {
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err == nil {
+			// 缓存对象没有扩展名，按内容识别类型
+			return data, http.DetectContentType(data), nil
+		}
+	}
+
+	imageTransformSlots <- struct{}{}
+	defer func() { <-imageTransformSlots }()
+
+	reader, _, err := Storage.Get(key)
+	if err != nil {
+		return nil, "", err
+	}
+	source, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return nil, "", fmt.Errorf("读取原图失败: %v", err)
+	}
+
+	mimeType := NormalizeContentType(http.DetectContentType(source))
+	if _, ok := allowedImageTypes[mimeType]; !ok {
+		return nil, "", ErrImageNotProcessable
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(source))
+	if err != nil || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, "", ErrImageNotProcessable
+	}
+	img, _, err := image.Decode(bytes.NewReader(source))
+	if err != nil {
+		return nil, "", ErrImageNotProcessable
+	}
+	if mimeType == "image/jpeg" {
+		if orientation := jpegOrientation(source); orientation > 1 {
+			img = applyOrientation(img, orientation)
+		}
+	}
+
+	outputType := imageTransformFormats[opts.Format]
+	if outputType == "" {
+		outputType = mimeType
+		if outputType == "image/gif" {
+			outputType = "image/png"
+		}
+	}
+
+	data, err := encodeImage(transformImage(img, opts), outputType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 缓存失败不影响本次返回
+	Storage.Put(cacheKey, bytes.NewReader(data), int64(len(data)), outputType)
+	return data, outputType, nil
+}
+
+// transformImage 按缩放方式调整图片尺寸，不放大原图
+func transformImage(img image.Image, opts ImageTransformOptions) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	width, height := opts.Width, opts.Height
+	if width == 0 && height == 0 {
+		return img
+	}
+
+	// 只指定一边或contain时等比缩放
+	if width == 0 || height == 0 || opts.Fit == ImageFitContain {
+		scale := 1.0
+		if width > 0 {
+			scale = float64(width) / float64(srcW)
+		}
+		if height > 0 && (width == 0 || float64(height)/float64(srcH) < scale) {
+			scale = float64(height) / float64(srcH)
+		}
+		if scale >= 1 {
+			return img
+		}
+		return scaleImage(img, bounds, max(1, int(float64(srcW)*scale+0.5)), max(1, int(float64(srcH)*scale+0.5)))
+	}
+
+	// 目标尺寸超过原图时按比例缩小目标尺寸，保持宽高比
+	if width > srcW || height > srcH {
+		shrink := min(float64(srcW)/float64(width), float64(srcH)/float64(height))
+		width = max(1, int(float64(width)*shrink))
+		height = max(1, int(float64(height)*shrink))
+	}
+
+	if opts.Fit == ImageFitFill {
+		return scaleImage(img, bounds, width, height)
+	}
+
+	// cover：从原图中心截取与目标宽高比相同的区域
+	crop := bounds
+	if srcW*height > srcH*width {
+		cropW := srcH * width / height
+		crop.Min.X += (srcW - cropW) / 2
+		crop.Max.X = crop.Min.X + cropW
+	} else {
+		cropH := srcW * height / width
+		crop.Min.Y += (srcH - cropH) / 2
+		crop.Max.Y = crop.Min.Y + cropH
+	}
+	return scaleImage(img, crop, width, height)
+}
+
+func scaleImage(img image.Image, src image.Rectangle, width, height int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+	return dst
+}
+
+// DeleteImageCache 删除原图的所有处理结果缓存
+func DeleteImageCache(key string) error {
+	objects, err := Storage.List(imageCacheDir(key))
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := Storage.Delete(object.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"blog-server/config"
+	"net/url"
+	"testing"
+)
+
+func TestParseImageTransformOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		width   string
+		height  string
+		fit     string
+		format  string
+		want    ImageTransformOptions
+		wantErr bool
+	}{
+		{"默认参数", "", "", "", "", ImageTransformOptions{Fit: ImageFitContain}, false},
+		{"宽高和裁剪", "300", "200", "cover", "", ImageTransformOptions{Width: 300, Height: 200, Fit: ImageFitCover}, false},
+		{"拉伸", "300", "200", "FILL", "", ImageTransformOptions{Width: 300, Height: 200, Fit: ImageFitFill}, false},
+		{"jpg统一为jpeg", "100", "", "", "JPG", ImageTransformOptions{Width: 100, Fit: ImageFitContain, Format: "jpeg"}, false},
+		{"webp", "", "100", "", "webp", ImageTransformOptions{Height: 100, Fit: ImageFitContain, Format: "webp"}, false},
+		{"最大宽度", "4096", "", "", "", ImageTransformOptions{Width: 4096, Fit: ImageFitContain}, false},
+		{"宽度超过上限", "4097", "", "", "", ImageTransformOptions{}, true},
+		{"宽度为0", "0", "", "", "", ImageTransformOptions{}, true},
+		{"宽度为负数", "-1", "", "", "", ImageTransformOptions{}, true},
+		{"宽度不是数字", "abc", "", "", "", ImageTransformOptions{}, true},
+		{"高度超过上限", "", "5000", "", "", ImageTransformOptions{}, true},
+		{"不支持的fit", "", "", "crop", "", ImageTransformOptions{}, true},
+		{"不支持的format", "", "", "", "gif", ImageTransformOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseImageTransformOptions(tt.width, tt.height, tt.fit, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseImageTransformOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseImageTransformOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageTransformSignature(t *testing.T) {
+	config.AppConfig = &config.Config{
+		ImageBaseURL:      "https://img.example.com",
+		StorageSigningKey: "secret",
+	}
+
+	key := "media/头像.png"
+	opts := ImageTransformOptions{Width: 300, Height: 200, Fit: ImageFitCover, Format: "webp"}
+
+	u, err := url.Parse(SignImageTransformURL(key, opts))
+	if err != nil {
+		t.Fatalf("解析URL失败: %v", err)
+	}
+	if u.Host != "img.example.com" || u.Path != "/img/media/头像.png" {
+		t.Fatalf("URL = %s", u)
+	}
+
+	// 按处理路由的方式从URL解析参数后校验
+	query := u.Query()
+	parsed, err := ParseImageTransformOptions(query.Get("w"), query.Get("h"), query.Get("fit"), query.Get("format"))
+	if err != nil {
+		t.Fatalf("ParseImageTransformOptions() error = %v", err)
+	}
+	signature := query.Get("s")
+
+	tests := []struct {
+		name      string
+		key       string
+		opts      ImageTransformOptions
+		signature string
+		wantErr   bool
+	}{
+		{"签名有效", key, parsed, signature, false},
+		{"篡改对象键", "media/other.png", parsed, signature, true},
+		{"篡改宽度", key, ImageTransformOptions{Width: 3000, Height: 200, Fit: ImageFitCover, Format: "webp"}, signature, true},
+		{"篡改裁剪方式", key, ImageTransformOptions{Width: 300, Height: 200, Fit: ImageFitFill, Format: "webp"}, signature, true},
+		{"篡改格式", key, ImageTransformOptions{Width: 300, Height: 200, Fit: ImageFitCover, Format: "png"}, signature, true},
+		{"签名为空", key, parsed, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyImageTransformSignature(tt.key, tt.opts, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyImageTransformSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 省略默认参数的URL与显式指定默认值的签名相同
+	defaults, _ := ParseImageTransformOptions("", "", "contain", "")
+	u, _ = url.Parse(SignImageTransformURL(key, ImageTransformOptions{Fit: ImageFitContain}))
+	if u.RawQuery != "s="+u.Query().Get("s") {
+		t.Errorf("默认参数不应出现在URL中: %s", u.RawQuery)
+	}
+	if err := VerifyImageTransformSignature(key, defaults, u.Query().Get("s")); err != nil {
+		t.Errorf("默认参数签名校验失败: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"blog-server/config"
+	"blog-server/models"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"image"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	// 注册图片解码器，用于读取图片尺寸
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// inspectHeadLimit 保留用于解析图片尺寸的文件头大小
+// JPEG的尺寸信息可能位于较大的EXIF段之后，因此保留得比嗅探长度多
+const inspectHeadLimit = 256 << 10
+
+// uploadTokenTTL 上传确认令牌的有效期
+const uploadTokenTTL = time.Hour
+
+// ImageMeta 文件内容信息
+type ImageMeta struct {
+	Size     int64
+	Hash     string
+	MimeType string
+	Width    int
+	Height   int
+}
+
+// ImageInspector 作为io.Writer在上传或读取文件时同步计算哈希、大小和图片尺寸
+type ImageInspector struct {
+	hash hash.Hash
+	head bytes.Buffer
+	size int64
+}
+
+// NewImageInspector 创建文件内容检查器
+func NewImageInspector() *ImageInspector {
+	return &ImageInspector{hash: sha256.New()}
+}
+
+func (i *ImageInspector) Write(p []byte) (int, error) {
+	i.hash.Write(p)
+	i.size += int64(len(p))
+	if remaining := inspectHeadLimit - i.head.Len(); remaining > 0 {
+		if len(p) > remaining {
+			i.head.Write(p[:remaining])
+		} else {
+			i.head.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// Head 返回文件开头用于嗅探的内容
+func (i *ImageInspector) Head() []byte {
+	head := i.head.Bytes()
+	if len(head) > SniffLength {
+		return head[:SniffLength]
+	}
+	return head
+}
+
+// Meta 返回已写入内容的信息，无法解析尺寸时宽高为0
+func (i *ImageInspector) Meta() ImageMeta {
+	meta := ImageMeta{
+		Size:     i.size,
+		Hash:     hex.EncodeToString(i.hash.Sum(nil)),
+		MimeType: NormalizeContentType(http.DetectContentType(i.Head())),
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(i.head.Bytes())); err == nil {
+		meta.Width = cfg.Width
+		meta.Height = cfg.Height
+	}
+	return meta
+}
+
+// SignUploadToken 为预签名上传生成确认令牌，绑定对象键、上传者和文件大小
+// 格式为 大小.过期时间.签名
+func SignUploadToken(key string, userID uint, size int64) string {
+	sizeStr := strconv.FormatInt(size, 10)
+	expires := strconv.FormatInt(time.Now().Add(uploadTokenTTL).Unix(), 10)
+	return sizeStr + "." + expires + "." + signUploadToken(key, userID, sizeStr, expires)
+}
+
+// VerifyUploadToken 校验上传确认令牌，返回签名时声明的文件大小
+func VerifyUploadToken(token, key string, userID uint) (int64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, errors.New("无效的上传令牌")
+	}
+
+	size, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, errors.New("无效的上传令牌")
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, errors.New("无效的上传令牌")
+	}
+	if time.Now().Unix() > expiresAt {
+		return 0, errors.New("上传令牌已过期")
+	}
+
+	expected := signUploadToken(key, userID, parts[0], parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return 0, errors.New("上传令牌无效")
+	}
+	return size, nil
+}
+
+func signUploadToken(key string, userID uint, size, expires string) string {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.StorageSigningKey))
+	fmt.Fprintf(mac, "upload\n%s\n%d\n%s\n%s", key, userID, size, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// KeyFromPublicURL 从公共访问URL中解析完整的对象键（包含目录前缀）
+// URL不属于当前存储时返回空字符串
+func KeyFromPublicURL(rawURL string) string {
+	prefix := Storage.PublicURL("")
+	if !strings.HasPrefix(rawURL, prefix) {
+		return ""
+	}
+
+	key := strings.TrimPrefix(rawURL, prefix)
+	if i := strings.IndexAny(key, "?#"); i >= 0 {
+		key = key[:i]
+	}
+	if unescaped, err := url.PathUnescape(key); err == nil {
+		key = unescaped
+	}
+	cleaned, err := cleanObjectKey(key)
+	if err != nil {
+		return ""
+	}
+	return cleaned
+}
+
+// SignPrivateMedia 为私有媒体生成临时访问地址，已加载的版本地址同样替换为签名URL
+func SignPrivateMedia(media *models.Media) {
+	if !media.Private {
+		return
+	}
+	if signed, err := SignedDownloadURL(media.Key); err == nil {
+		media.SignedURL = signed
+	}
+	for i := range media.Variants {
+		if signed, err := SignedDownloadURL(media.Variants[i].Key); err == nil {
+			media.Variants[i].URL = signed
+		}
+	}
+}
+
+// PrivateKeyFromSignedURL 从私有文件的签名URL中解析对象键
+// 签名URL的格式取决于存储驱动，这里按路径中的私有前缀定位对象键
+func PrivateKeyFromSignedURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	i := strings.Index(parsed.Path, "/"+PrivateMediaPrefix)
+	if i < 0 {
+		return ""
+	}
+	key, err := cleanObjectKey(parsed.Path[i+1:])
+	if err != nil {
+		return ""
+	}
+	return key
+}
@@ -0,0 +1,309 @@
+package utils
+
+import (
+	"blog-server/models"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// mediaProcessingSlots 限制同时处理的图片数量，避免大图解码占用过多内存
+var mediaProcessingSlots = make(chan struct{}, 2)
+
+// CreateMedia 登记上传成功的文件，按内容哈希去重
+// 已存在相同内容的文件时删除刚上传的对象，新记录引用已有文件（返回的媒体Key与上传的key不同）；
+// 同一用户重复上传相同内容时直接返回已有记录，created为false
+func CreateMedia(key, originalName string, ownerID uint, meta ImageMeta) (media *models.Media, created bool, err error) {
+	// 私有文件和公开文件分别去重，避免私有内容通过公开地址访问
+	private := IsPrivateKey(key)
+
+	// 上传的对象可能因去重被删除，先记录对象键对应的媒体，重复确认同一次上传时按此查找
+	var existing models.Media
+	if err := models.DB.Where("owner_id = ? AND hash = ? AND private = ?", ownerID, meta.Hash, private).First(&existing).Error; err == nil {
+		if err := recordMediaUpload(models.DB, key, existing.ID); err != nil {
+			return nil, false, err
+		}
+		if existing.Key != key {
+			Storage.Delete(key)
+		}
+		return &existing, false, nil
+	}
+
+	var blob models.MediaBlob
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("hash = ? AND private = ?", meta.Hash, private).
+			Order("id ASC").
+			First(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			blob = models.MediaBlob{
+				Hash:     meta.Hash,
+				Key:      key,
+				Size:     meta.Size,
+				MimeType: meta.MimeType,
+				Private:  private,
+			}
+			if err := tx.Create(&blob).Error; err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count + 1")).Error; err != nil {
+			return err
+		}
+
+		record := models.Media{
+			BlobID:       blob.ID,
+			Key:          blob.Key,
+			URL:          GeneratePublicURL(blob.Key),
+			OwnerID:      ownerID,
+			OriginalName: originalName,
+			Size:         meta.Size,
+			MimeType:     meta.MimeType,
+			Width:        meta.Width,
+			Height:       meta.Height,
+			Hash:         meta.Hash,
+			Status:       models.MediaStatusPending,
+			Private:      private,
+		}
+
+		// 复用已有文件时沿用已处理的结果
+		var sibling models.Media
+		if tx.Where("blob_id = ?", blob.ID).First(&sibling).Error == nil {
+			record.URL = sibling.URL
+			record.Size = sibling.Size
+			record.Width = sibling.Width
+			record.Height = sibling.Height
+			record.Status = sibling.Status
+		}
+
+		media = &record
+		if err := tx.Create(media).Error; err != nil {
+			return err
+		}
+		return recordMediaUpload(tx, key, media.ID)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 每条媒体记录都计入上传者的存储用量（包括共享的缩放版本）
+	AddStorageUsage(ownerID, media.Size+blobVariantsSize(blob.ID))
+
+	if blob.Key != key {
+		Storage.Delete(key)
+	} else {
+		ProcessMediaAsync(media.ID)
+	}
+	return media, true, nil
+}
+
+// recordMediaUpload 记录上传的对象键对应的媒体，同一对象键已有记录时保留原记录
+func recordMediaUpload(tx *gorm.DB, key string, mediaID uint) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.MediaUpload{Key: key, MediaID: mediaID}).Error
+}
+
+// ProcessMediaAsync 在后台为媒体生成响应式版本
+func ProcessMediaAsync(mediaID uint) {
+	go func() {
+		mediaProcessingSlots <- struct{}{}
+		defer func() { <-mediaProcessingSlots }()
+
+		if err := ProcessMedia(mediaID); err != nil {
+			log.Printf("处理媒体 %d 失败: %v", mediaID, err)
+		}
+	}()
+}
+
+// ProcessMedia 去除原图元数据，生成缩放和WebP版本
+// 结果由引用同一文件的所有媒体记录共享，重复处理时会先删除旧的版本
+func ProcessMedia(mediaID uint) error {
+	var media models.Media
+	if err := models.DB.First(&media, mediaID).Error; err != nil {
+		return err
+	}
+	var blob models.MediaBlob
+	if err := models.DB.First(&blob, media.BlobID).Error; err != nil {
+		return err
+	}
+
+	if _, ok := allowedImageTypes[blob.MimeType]; !ok {
+		return setBlobStatus(&blob, models.MediaStatusSkipped)
+	}
+
+	reader, _, err := Storage.Get(blob.Key)
+	if err != nil {
+		setBlobStatus(&blob, models.MediaStatusFailed)
+		return err
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		setBlobStatus(&blob, models.MediaStatusFailed)
+		return fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	result, err := ProcessImage(data, blob.MimeType)
+	if errors.Is(err, ErrImageNotProcessable) {
+		return setBlobStatus(&blob, models.MediaStatusSkipped)
+	}
+	if err != nil {
+		setBlobStatus(&blob, models.MediaStatusFailed)
+		return err
+	}
+
+	if err := deleteBlobVariants(&blob); err != nil {
+		setBlobStatus(&blob, models.MediaStatusFailed)
+		return err
+	}
+
+	// 用去除元数据后的版本覆盖原图，哈希仍保留上传内容的哈希，用于去重
+	size := int64(len(data))
+	if result.Original != nil {
+		if err := Storage.Put(blob.Key, bytes.NewReader(result.Original), int64(len(result.Original)), blob.MimeType); err != nil {
+			setBlobStatus(&blob, models.MediaStatusFailed)
+			return err
+		}
+		size = int64(len(result.Original))
+	}
+
+	var variantsSize int64
+	base := strings.TrimSuffix(blob.Key, path.Ext(blob.Key))
+	for _, encoded := range result.Variants {
+		key := base + encoded.Suffix + encoded.Ext
+		if err := Storage.Put(key, bytes.NewReader(encoded.Data), int64(len(encoded.Data)), encoded.MimeType); err != nil {
+			setBlobStatus(&blob, models.MediaStatusFailed)
+			return err
+		}
+
+		variant := models.MediaVariant{
+			BlobID:   blob.ID,
+			Key:      key,
+			URL:      GeneratePublicURL(key),
+			Width:    encoded.Width,
+			Height:   encoded.Height,
+			Size:     int64(len(encoded.Data)),
+			MimeType: encoded.MimeType,
+		}
+		if err := models.DB.Create(&variant).Error; err != nil {
+			Storage.Delete(key)
+			setBlobStatus(&blob, models.MediaStatusFailed)
+			return err
+		}
+		variantsSize += variant.Size
+	}
+
+	// 原图大小变化和缩放版本计入每个引用者的存储用量
+	chargeBlobOwners(blob.ID, size-blob.Size+variantsSize)
+	if err := models.DB.Model(&blob).Update("size", size).Error; err != nil {
+		return err
+	}
+
+	return models.DB.Model(&models.Media{}).Where("blob_id = ?", blob.ID).Updates(map[string]interface{}{
+		"width":  result.Width,
+		"height": result.Height,
+		"size":   size,
+		"status": models.MediaStatusReady,
+	}).Error
+}
+
+// DeleteMedia 删除媒体记录并释放上传者的存储用量
+// 文件没有其他引用时才删除原图、所有版本和实时处理的缓存
+func DeleteMedia(media *models.Media) error {
+	variantsSize := blobVariantsSize(media.BlobID)
+
+	var blob models.MediaBlob
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(media).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("media_id = ?", media.ID).Delete(&models.MediaUpload{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&blob, media.BlobID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		blob.RefCount--
+		if blob.RefCount > 0 {
+			return tx.Model(&blob).Update("ref_count", blob.RefCount).Error
+		}
+		return tx.Delete(&blob).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	AddStorageUsage(media.OwnerID, -(media.Size + variantsSize))
+
+	if blob.ID == 0 || blob.RefCount > 0 {
+		return nil
+	}
+	if err := deleteBlobVariants(&blob); err != nil {
+		return err
+	}
+	if err := DeleteImageCache(blob.Key); err != nil {
+		return err
+	}
+	return Storage.Delete(blob.Key)
+}
+
+// deleteBlobVariants 删除文件已生成的所有版本，并释放各引用者的存储用量
+func deleteBlobVariants(blob *models.MediaBlob) error {
+	var variants []models.MediaVariant
+	if err := models.DB.Where("blob_id = ?", blob.ID).Find(&variants).Error; err != nil {
+		return err
+	}
+
+	var size int64
+	for _, variant := range variants {
+		if err := Storage.Delete(variant.Key); err != nil {
+			return err
+		}
+		size += variant.Size
+	}
+	if err := models.DB.Where("blob_id = ?", blob.ID).Delete(&models.MediaVariant{}).Error; err != nil {
+		return err
+	}
+	chargeBlobOwners(blob.ID, -size)
+	return nil
+}
+
+// blobVariantsSize 文件所有版本的总大小
+func blobVariantsSize(blobID uint) int64 {
+	var size int64
+	models.DB.Model(&models.MediaVariant{}).
+		Where("blob_id = ?", blobID).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&size)
+	return size
+}
+
+// chargeBlobOwners 为引用同一文件的每条媒体记录的上传者增加存储用量
+func chargeBlobOwners(blobID uint, delta int64) {
+	if delta == 0 {
+		return
+	}
+	var ownerIDs []uint
+	models.DB.Model(&models.Media{}).Where("blob_id = ?", blobID).Pluck("owner_id", &ownerIDs)
+	for _, ownerID := range ownerIDs {
+		AddStorageUsage(ownerID, delta)
+	}
+}
+
+// setBlobStatus 更新引用同一文件的所有媒体记录的处理状态
+func setBlobStatus(blob *models.MediaBlob, status string) error {
+	return models.DB.Model(&models.Media{}).Where("blob_id = ?", blob.ID).Update("status", status).Error
+}
@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"blog-server/config"
+	"blog-server/models"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrphanScanPrefix 参与孤立文件扫描的对象前缀，私有前缀下的同名目录同样扫描
+const OrphanScanPrefix = "images/"
+
+// ErrOrphanScanRunning 已有扫描或清理正在进行
+var ErrOrphanScanRunning = errors.New("已有孤立文件扫描或清理正在进行")
+
+// orphanMutex 同一时间只允许一个扫描或清理任务
+var orphanMutex sync.Mutex
+
+// referencedURLPattern 匹配正文中出现的所有URL（包括以/开头的相对地址），不限于图片语法，避免误删被链接引用的文件
+var referencedURLPattern = regexp.MustCompile(`(?:https?://|/)[^\s"'<>()\[\]]+`)
+
+// ScanOrphans 扫描存储中未被文章正文和个人资料引用的文件，只生成报告不删除
+// 最近修改时间在宽限期内的文件不视为孤立（可能正在编辑的文章还未保存）
+// 媒体库中的原图同样按引用判断，其缩放版本随原图一起删除，不单独列入报告
+func ScanOrphans(trigger string) (*models.OrphanScan, error) {
+	if !orphanMutex.TryLock() {
+		return nil, ErrOrphanScanRunning
+	}
+	defer orphanMutex.Unlock()
+
+	referenced, err := collectReferencedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []ObjectInfo
+	for _, prefix := range []string{OrphanScanPrefix, PrivateMediaPrefix + OrphanScanPrefix} {
+		listed, err := Storage.List(prefix)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, listed...)
+	}
+
+	library, err := mediaLibraryFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	graceHours := config.AppConfig.OrphanGraceHours
+	cutoff := time.Now().Add(-time.Duration(graceHours) * time.Hour)
+
+	scan := models.OrphanScan{
+		Trigger:     trigger,
+		GraceHours:  graceHours,
+		ObjectCount: len(objects),
+	}
+	for _, object := range objects {
+		if referenced[object.Key] {
+			scan.ReferencedCount++
+			continue
+		}
+		file, inLibrary := library[object.Key]
+		if inLibrary && file.variant {
+			continue
+		}
+		if object.LastModified.After(cutoff) || (inLibrary && file.lastCreated.After(cutoff)) {
+			continue
+		}
+
+		orphan := models.OrphanObject{
+			Key:          object.Key,
+			URL:          GeneratePublicURL(object.Key),
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		}
+		if inLibrary {
+			orphan.MediaID = &file.mediaID
+			scan.MediaCount++
+		}
+		scan.Objects = append(scan.Objects, orphan)
+		scan.OrphanSize += object.Size
+	}
+	scan.OrphanCount = len(scan.Objects)
+
+	if err := models.DB.Create(&scan).Error; err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+// PurgeOrphanScan 删除报告中的孤立文件
+// 删除前重新检查引用，报告生成后被重新引用、成为缩放版本或在宽限期内重新上传到媒体库的文件会跳过
+// 媒体库的文件通过DeleteMedia删除，保持引用数、缩放版本和存储用量一致
+func PurgeOrphanScan(scan *models.OrphanScan, actorID uint) (int, error) {
+	if !orphanMutex.TryLock() {
+		return 0, ErrOrphanScanRunning
+	}
+	defer orphanMutex.Unlock()
+
+	referenced, err := collectReferencedKeys()
+	if err != nil {
+		return 0, err
+	}
+	library, err := mediaLibraryFiles()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-time.Duration(scan.GraceHours) * time.Hour)
+
+	var objects []models.OrphanObject
+	if err := models.DB.Where("scan_id = ? AND purged_at IS NULL AND skipped = ?", scan.ID, false).
+		Find(&objects).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, object := range objects {
+		file, inLibrary := library[object.Key]
+		if referenced[object.Key] || (inLibrary && (file.variant || file.lastCreated.After(cutoff))) {
+			models.DB.Model(&object).Update("skipped", true)
+			continue
+		}
+
+		if err := deleteOrphanObject(object.Key); err != nil {
+			return purged, err
+		}
+
+		models.DB.Model(&object).Update("purged_at", time.Now())
+		purged++
+	}
+
+	err = models.DB.Model(scan).Updates(map[string]interface{}{
+		"purged_count": gorm.Expr("purged_count + ?", purged),
+		"purged_by_id": actorID,
+		"purged_at":    time.Now(),
+	}).Error
+	return purged, err
+}
+
+// collectReferencedKeys 收集文章正文和个人资料中引用的对象键
+// 被引用的媒体原图的所有缩放版本同样视为被引用
+func collectReferencedKeys() (map[string]bool, error) {
+	// 媒体库记录的URL，用于公共URL配置变更后仍能识别旧链接
+	var mediaList []models.Media
+	if err := models.DB.Select("id", "key", "url").Find(&mediaList).Error; err != nil {
+		return nil, err
+	}
+	keyByURL := make(map[string]string, len(mediaList))
+	for _, media := range mediaList {
+		keyByURL[media.URL] = media.Key
+	}
+
+	referenced := map[string]bool{}
+	addText := func(text string) {
+		for _, rawURL := range referencedURLPattern.FindAllString(text, -1) {
+			if key, ok := keyByURL[rawURL]; ok {
+				referenced[key] = true
+			} else if key := referencedObjectKey(rawURL); key != "" {
+				referenced[key] = true
+			}
+		}
+	}
+
+	// 软删除的文章不会被查询到，其引用的图片会被视为孤立
+	var contents []models.ArticleContent
+	err := models.DB.Select("id", "content").
+		Where("article_id IN (?)", models.DB.Model(&models.Article{}).Select("id")).
+		FindInBatches(&contents, 200, func(tx *gorm.DB, batch int) error {
+			for _, content := range contents {
+				addText(content.Content)
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []models.Profile
+	if err := models.DB.Select("id", "avatar", "bio").Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	for _, profile := range profiles {
+		addText(profile.Avatar)
+		addText(profile.Bio)
+	}
+
+	// 原图被引用时，其缩放和WebP版本也被引用
+	var blobs []models.MediaBlob
+	if err := models.DB.Select("id", "key").Find(&blobs).Error; err != nil {
+		return nil, err
+	}
+	keyByBlob := make(map[uint]string, len(blobs))
+	for _, blob := range blobs {
+		keyByBlob[blob.ID] = blob.Key
+	}
+	var variants []models.MediaVariant
+	if err := models.DB.Select("id", "blob_id", "key").Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	for _, variant := range variants {
+		if referenced[keyByBlob[variant.BlobID]] {
+			referenced[variant.Key] = true
+		}
+	}
+
+	return referenced, nil
+}
+
+// referencedObjectKey 解析URL引用的对象键，无法识别时返回空字符串
+// 支持存储的公共URL、图片处理URL（/img/）、私有文件的签名URL，以及省略域名的相对地址
+// 只按路径匹配、不比较域名，误判为引用只会少删文件
+func referencedObjectKey(rawURL string) string {
+	if key := KeyFromPublicURL(rawURL); key != "" {
+		return key
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	for _, base := range []string{Storage.PublicURL(""), config.AppConfig.ImageBaseURL + ImageTransformRoutePrefix} {
+		if key := keyFromURLPath(parsed.Path, base); key != "" {
+			return key
+		}
+	}
+	return PrivateKeyFromSignedURL(rawURL)
+}
+
+// keyFromURLPath 去掉基础地址的路径前缀得到对象键
+func keyFromURLPath(path, base string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	prefix := baseURL.Path
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	key, ok := strings.CutPrefix(path, prefix)
+	if !ok {
+		return ""
+	}
+	cleaned, err := cleanObjectKey(key)
+	if err != nil {
+		return ""
+	}
+	return cleaned
+}
+
+// libraryFile 媒体库中的文件
+type libraryFile struct {
+	mediaID     uint      // 引用该文件的最早一条媒体记录
+	lastCreated time.Time // 最近一条引用该文件的媒体记录的创建时间
+	variant     bool      // 是否为媒体库文件的缩放版本
+}
+
+// mediaLibraryFiles 按对象键索引媒体库记录的原图及其缩放版本
+func mediaLibraryFiles() (map[string]libraryFile, error) {
+	var mediaList []models.Media
+	if err := models.DB.Select("id", "blob_id", "key", "created_at").Order("id").Find(&mediaList).Error; err != nil {
+		return nil, err
+	}
+	var variants []models.MediaVariant
+	if err := models.DB.Select("id", "blob_id", "key").Find(&variants).Error; err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]libraryFile, len(mediaList)+len(variants))
+	ownedBlobs := make(map[uint]bool, len(mediaList))
+	for _, media := range mediaList {
+		file, ok := files[media.Key]
+		if !ok {
+			file.mediaID = media.ID
+		}
+		if media.CreatedAt.After(file.lastCreated) {
+			file.lastCreated = media.CreatedAt
+		}
+		files[media.Key] = file
+		ownedBlobs[media.BlobID] = true
+	}
+	for _, variant := range variants {
+		if ownedBlobs[variant.BlobID] {
+			files[variant.Key] = libraryFile{variant: true}
+		}
+	}
+	return files, nil
+}
+
+// deleteOrphanObject 删除孤立对象
+// 对象是媒体原图时删除引用它的所有媒体记录，最后一条记录删除时会同时删除文件及其所有版本；
+// 对象是已没有媒体记录的缩放版本时同时删除版本记录
+func deleteOrphanObject(key string) error {
+	var mediaList []models.Media
+	if err := models.DB.Where("key = ?", key).Find(&mediaList).Error; err != nil {
+		return err
+	}
+	if len(mediaList) > 0 {
+		for i := range mediaList {
+			if err := DeleteMedia(&mediaList[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := Storage.Delete(key); err != nil {
+		return err
+	}
+	if err := DeleteImageCache(key); err != nil {
+		return err
+	}
+
+	var variant models.MediaVariant
+	if err := models.DB.Where("key = ?", key).First(&variant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := models.DB.Delete(&variant).Error; err != nil {
+		return err
+	}
+	chargeBlobOwners(variant.BlobID, -variant.Size)
+	return nil
+}
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"blog-server/config"
+	"blog-server/models"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectReferencedKeys(t *testing.T) {
+	setupTestDB(t, &models.User{}, &models.Article{}, &models.ArticleContent{}, &models.Profile{},
+		&models.Media{}, &models.MediaBlob{}, &models.MediaVariant{})
+
+	config.AppConfig = &config.Config{ImageBaseURL: "https://img.example.com"}
+	previous := Storage
+	Storage = NewMemoryStorage("https://cdn.example.com", "secret")
+	t.Cleanup(func() { Storage = previous })
+
+	signedURL, err := Storage.PresignDownload("private/images/signed.png", time.Hour)
+	if err != nil {
+		t.Fatalf("PresignDownload() error = %v", err)
+	}
+
+	content := "![公共URL](https://cdn.example.com/storage/images/public.png)\n" +
+		"[附件](/storage/images/relative.pdf)\n" +
+		`<img src="https://img.example.com/img/images/transform.png?w=300&s=abc">` + "\n" +
+		"![私有](" + signedURL + ")\n" +
+		"旧域名 https://old-cdn.example.com/images/legacy.png 的链接\n" +
+		"![编码](https://cdn.example.com/storage/images/%E4%B8%AD%E6%96%87.png)\n" +
+		"![外部](https://other.example.com/images/external.png)\n" +
+		"[穿越](/storage/../etc/passwd)\n"
+
+	article := models.Article{Title: "文章", UserID: 1}
+	deleted := models.Article{Title: "已删除", UserID: 1}
+	if err := models.DB.Create(&[]*models.Article{&article, &deleted}).Error; err != nil {
+		t.Fatalf("创建文章失败: %v", err)
+	}
+	models.DB.Create(&models.ArticleContent{ArticleID: article.ID, Content: content})
+	models.DB.Create(&models.ArticleContent{ArticleID: deleted.ID, Content: "![](/storage/images/deleted.png)"})
+	models.DB.Delete(&deleted)
+
+	models.DB.Create(&models.Profile{
+		Name:   "作者",
+		Avatar: "https://cdn.example.com/storage/images/avatar.png",
+		Bio:    "简介里的图片 /storage/images/bio.png",
+	})
+
+	// 公共URL配置变更前上传的媒体，按媒体库记录的URL识别
+	legacyBlob := models.MediaBlob{Hash: "legacy", Key: "images/legacy.png"}
+	publicBlob := models.MediaBlob{Hash: "public", Key: "images/public.png"}
+	unusedBlob := models.MediaBlob{Hash: "unused", Key: "images/unused.png"}
+	models.DB.Create(&[]*models.MediaBlob{&legacyBlob, &publicBlob, &unusedBlob})
+	models.DB.Create(&models.Media{BlobID: legacyBlob.ID, Key: legacyBlob.Key, URL: "https://old-cdn.example.com/images/legacy.png", OwnerID: 1})
+	models.DB.Create(&[]models.MediaVariant{
+		{BlobID: publicBlob.ID, Key: "images/public_300.webp", URL: "https://cdn.example.com/storage/images/public_300.webp"},
+		{BlobID: unusedBlob.ID, Key: "images/unused_300.webp", URL: "https://cdn.example.com/storage/images/unused_300.webp"},
+	})
+
+	referenced, err := collectReferencedKeys()
+	if err != nil {
+		t.Fatalf("collectReferencedKeys() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want bool
+	}{
+		{"存储的公共URL", "images/public.png", true},
+		{"省略域名的相对地址", "images/relative.pdf", true},
+		{"图片处理URL", "images/transform.png", true},
+		{"私有文件的签名URL", "private/images/signed.png", true},
+		{"媒体库记录的旧URL", "images/legacy.png", true},
+		{"URL编码的文件名", "images/中文.png", true},
+		{"个人资料头像", "images/avatar.png", true},
+		{"个人介绍中的地址", "images/bio.png", true},
+		{"被引用原图的缩放版本", "images/public_300.webp", true},
+		{"未被引用原图的缩放版本", "images/unused_300.webp", false},
+		{"已删除文章中的引用", "images/deleted.png", false},
+		{"外部网站的图片", "images/external.png", false},
+		{"路径穿越", "etc/passwd", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if referenced[tt.key] != tt.want {
+				t.Errorf("referenced[%q] = %v, want %v", tt.key, referenced[tt.key], tt.want)
+			}
+		})
+	}
+
+	var want []string
+	for _, tt := range tests {
+		if tt.want {
+			want = append(want, tt.key)
+		}
+	}
+	if len(referenced) != len(want) {
+		var got []string
+		for key := range referenced {
+			got = append(got, key)
+		}
+		sort.Strings(got)
+		t.Errorf("collectReferencedKeys() = %v, want %d个键", got, len(want))
+	}
+}
+
+func TestScanAndPurgeOrphans(t *testing.T) {
+	setupTestDB(t, &models.User{}, &models.Article{}, &models.ArticleContent{}, &models.Profile{},
+		&models.Media{}, &models.MediaBlob{}, &models.MediaVariant{}, &models.MediaUpload{},
+		&models.OrphanScan{}, &models.OrphanObject{})
+
+	config.AppConfig = &config.Config{ImageBaseURL: "https://img.example.com", OrphanGraceHours: 0}
+	previous := Storage
+	Storage = NewMemoryStorage("https://cdn.example.com", "secret")
+	t.Cleanup(func() { Storage = previous })
+
+	for _, key := range []string{"images/kept.png", "images/kept_300.webp", "images/dropped.png",
+		"images/dropped_300.webp", "images/stray.png", "images/relinked.png"} {
+		if err := Storage.Put(key, strings.NewReader("data"), 4, ""); err != nil {
+			t.Fatalf("Put(%q) error = %v", key, err)
+		}
+	}
+
+	// 被文章引用的媒体
+	keptBlob := models.MediaBlob{Hash: "kept", Key: "images/kept.png", RefCount: 1}
+	// 从文章中移除、被两个用户上传过的媒体
+	droppedBlob := models.MediaBlob{Hash: "dropped", Key: "images/dropped.png", RefCount: 2}
+	models.DB.Create(&[]*models.MediaBlob{&keptBlob, &droppedBlob})
+	models.DB.Create(&[]models.Media{
+		{BlobID: keptBlob.ID, Key: keptBlob.Key, URL: Storage.PublicURL(keptBlob.Key), OwnerID: 1},
+		{BlobID: droppedBlob.ID, Key: droppedBlob.Key, URL: Storage.PublicURL(droppedBlob.Key), OwnerID: 1},
+		{BlobID: droppedBlob.ID, Key: droppedBlob.Key, URL: Storage.PublicURL(droppedBlob.Key), OwnerID: 2},
+	})
+	models.DB.Create(&[]models.MediaVariant{
+		{BlobID: keptBlob.ID, Key: "images/kept_300.webp", URL: Storage.PublicURL("images/kept_300.webp")},
+		{BlobID: droppedBlob.ID, Key: "images/dropped_300.webp", URL: Storage.PublicURL("images/dropped_300.webp")},
+	})
+
+	article := models.Article{Title: "文章", UserID: 1}
+	models.DB.Create(&article)
+	models.DB.Create(&models.ArticleContent{ArticleID: article.ID, Content: "![](" + Storage.PublicURL("images/kept.png") + ")"})
+
+	scan, err := ScanOrphans("test")
+	if err != nil {
+		t.Fatalf("ScanOrphans() error = %v", err)
+	}
+
+	reported := map[string]models.OrphanObject{}
+	for _, object := range scan.Objects {
+		reported[object.Key] = object
+	}
+	wantReported := []string{"images/dropped.png", "images/relinked.png", "images/stray.png"}
+	if len(reported) != len(wantReported) {
+		t.Fatalf("报告中的文件 = %v, want %v", scan.Objects, wantReported)
+	}
+	for _, key := range wantReported {
+		if _, ok := reported[key]; !ok {
+			t.Errorf("报告中缺少 %s", key)
+		}
+	}
+	if object := reported["images/dropped.png"]; object.MediaID == nil {
+		t.Errorf("媒体库文件没有记录媒体ID")
+	}
+	if scan.ReferencedCount != 2 || scan.MediaCount != 1 {
+		t.Errorf("ReferencedCount = %d, MediaCount = %d, want 2, 1", scan.ReferencedCount, scan.MediaCount)
+	}
+
+	// 扫描后重新被引用的文件在清理时跳过
+	models.DB.Create(&models.Profile{Name: "作者", Avatar: Storage.PublicURL("images/relinked.png")})
+
+	purged, err := PurgeOrphanScan(scan, 1)
+	if err != nil {
+		t.Fatalf("PurgeOrphanScan() error = %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("PurgeOrphanScan() = %d, want 2", purged)
+	}
+
+	tests := []struct {
+		key    string
+		exists bool
+	}{
+		{"images/kept.png", true},
+		{"images/kept_300.webp", true},
+		{"images/relinked.png", true},
+		{"images/dropped.png", false},
+		{"images/dropped_300.webp", false},
+		{"images/stray.png", false},
+	}
+	for _, tt := range tests {
+		if _, err := Storage.Stat(tt.key); (err == nil) != tt.exists {
+			t.Errorf("清理后 %s 存在 = %v, want %v", tt.key, err == nil, tt.exists)
+		}
+	}
+
+	var mediaCount, blobCount, variantCount int64
+	models.DB.Model(&models.Media{}).Where("blob_id = ?", droppedBlob.ID).Count(&mediaCount)
+	models.DB.Model(&models.MediaBlob{}).Where("id = ?", droppedBlob.ID).Count(&blobCount)
+	models.DB.Model(&models.MediaVariant{}).Where("blob_id = ?", droppedBlob.ID).Count(&variantCount)
+	if mediaCount != 0 || blobCount != 0 || variantCount != 0 {
+		t.Errorf("清理后仍有媒体记录%d、文件记录%d、版本记录%d", mediaCount, blobCount, variantCount)
+	}
+
+	var skipped models.OrphanObject
+	models.DB.Where("scan_id = ? AND key = ?", scan.ID, "images/relinked.png").First(&skipped)
+	if !skipped.Skipped || skipped.PurgedAt != nil {
+		t.Errorf("重新引用的文件 skipped = %v, purged_at = %v", skipped.Skipped, skipped.PurgedAt)
+	}
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"blog-server/config"
+	"blog-server/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrStorageQuotaExceeded 超出存储配额
+var ErrStorageQuotaExceeded = errors.New("存储空间不足，已超出配额")
+
+// StorageQuotaFor 用户的存储配额（字节），0表示不限
+// 未单独设置时使用角色的默认配额
+func StorageQuotaFor(user *models.User) int64 {
+	if user.StorageQuota != nil {
+		return *user.StorageQuota
+	}
+	if user.IsAdmin() {
+		return config.AppConfig.StorageQuotaAdmin
+	}
+	return config.AppConfig.StorageQuotaContributor
+}
+
+// RemainingStorage 用户剩余的存储空间，不限配额时返回-1
+func RemainingStorage(user *models.User) int64 {
+	quota := StorageQuotaFor(user)
+	if quota <= 0 {
+		return -1
+	}
+	if remaining := quota - user.StorageUsed; remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// CheckStorageQuota 检查用户再上传size字节后是否超出配额
+func CheckStorageQuota(user *models.User, size int64) error {
+	remaining := RemainingStorage(user)
+	if remaining >= 0 && size > remaining {
+		return ErrStorageQuotaExceeded
+	}
+	return nil
+}
+
+// AddStorageUsage 增加（delta为负时减少）用户的存储用量
+func AddStorageUsage(userID uint, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	return models.DB.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("storage_used", gorm.Expr("GREATEST(storage_used + ?, 0)", delta)).Error
+}
@@ -76,54 +76,154 @@ type TrackingData struct {
 	Timestamp time.Time `json:"timestamp"`
 	Path      string    `json:"path"`
 	IPAddress string    `json:"ip_address"`
+	VisitorID string    `json:"visitor_id,omitempty"`
+	ClientID  string    `json:"client_id,omitempty"`
 	UserAgent string    `json:"user_agent_hash"`
 	Referer   string    `json:"referer"`
 	EventType string    `json:"event_type"`
 	ArticleID *uint     `json:"article_id,omitempty"`
 	SessionID string    `json:"session_id"`
+	Country   string    `json:"country,omitempty"`
+	City      string    `json:"city,omitempty"`
+
+	// 收集时从User-Agent解析出的信息
+	Browser        string `json:"browser,omitempty"`
+	BrowserVersion string `json:"browser_version,omitempty"`
+	OS             string `json:"os,omitempty"`
+	DeviceType     string `json:"device_type,omitempty"`
+
+	IsBot     bool   `json:"is_bot,omitempty"`
+	BotReason string `json:"bot_reason,omitempty"`
+
+	// 自定义属性，收集时已校验过格式和大小
+	Properties json.RawMessage `json:"properties,omitempty"`
 }
 
-// StoreTrackingData 存储追踪数据到Redis
-func StoreTrackingData(ctx context.Context, data TrackingData) error {
-	// 存储到今天的列表中
-	todayKey := GetTodayKey("tracking")
-	
-	// 序列化数据
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("序列化追踪数据失败: %v", err)
+// trackingKeyTTL 实时数据在Redis中的保留时间
+const trackingKeyTTL = 72 * time.Hour
+
+// onlineWindow 在线用户的判定时间窗口
+const onlineWindow = 30 * time.Minute
+
+// TrackingEventsChannel 实时事件的发布频道，各实例的实时推送连接都订阅这个频道
+const TrackingEventsChannel = "analytics:events"
+
+// LiveTrackingEvent 推送给实时面板的事件，不包含访客ID和IP
+type LiveTrackingEvent struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Path       string    `json:"path"`
+	EventType  string    `json:"event_type"`
+	ArticleID  *uint     `json:"article_id,omitempty"`
+	Country    string    `json:"country,omitempty"`
+	City       string    `json:"city,omitempty"`
+	Browser    string    `json:"browser,omitempty"`
+	OS         string    `json:"os,omitempty"`
+	DeviceType string    `json:"device_type,omitempty"`
+	IsBot      bool      `json:"is_bot,omitempty"`
+
+	Properties json.RawMessage `json:"properties,omitempty"`
+}
+
+// StoreTrackingBatch 存储一批追踪数据并更新实时统计
+// 所有写入通过一个pipeline发送，只有出现新访客时才需要再更新一次访客数
+func StoreTrackingBatch(ctx context.Context, batch []TrackingData) error {
+	if len(batch) == 0 {
+		return nil
 	}
 
-	// 存储到Redis列表
-	err = RedisClient.LPush(ctx, todayKey, jsonData).Err()
+	trackingKey := GetTodayKey("tracking")
+	values := make([]interface{}, 0, len(batch))
+	liveEvents := make([]LiveTrackingEvent, 0, len(batch))
+	for _, data := range batch {
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("序列化追踪数据失败: %v", err)
+		}
+		values = append(values, jsonData)
+		liveEvents = append(liveEvents, LiveTrackingEvent{
+			Timestamp:  data.Timestamp,
+			Path:       data.Path,
+			EventType:  data.EventType,
+			ArticleID:  data.ArticleID,
+			Country:    data.Country,
+			City:       data.City,
+			Browser:    data.Browser,
+			OS:         data.OS,
+			DeviceType: data.DeviceType,
+			IsBot:      data.IsBot,
+			Properties: data.Properties,
+		})
+	}
+	liveMessage, err := json.Marshal(liveEvents)
 	if err != nil {
-		return fmt.Errorf("存储追踪数据到Redis失败: %v", err)
+		return fmt.Errorf("序列化实时事件失败: %v", err)
 	}
 
-	// 设置键的过期时间为3天
-	RedisClient.Expire(ctx, todayKey, 72*time.Hour)
+	now := time.Now()
+	expiredScore := fmt.Sprintf("%d", now.Add(-onlineWindow).Unix())
 
-	return nil
-}
+	// 机器人的统计与正常访客分开存储
+	type visitorAdd struct {
+		statsKey string
+		cmd      *redis.IntCmd
+	}
+	var visitorAdds []visitorAdd
 
-// UpdateOnlineUsers 更新在线用户数量
-func UpdateOnlineUsers(ctx context.Context, ipAddress string) error {
-	key := "online_users"
-	// 使用IP地址作为成员，设置30分钟过期
-	score := float64(time.Now().Unix())
-	
-	// 添加到有序集合
-	err := RedisClient.ZAdd(ctx, key, redis.Z{
-		Score:  score,
-		Member: ipAddress,
-	}).Err()
-	if err != nil {
-		return err
+	pipe := RedisClient.Pipeline()
+	pipe.LPush(ctx, trackingKey, values...)
+	pipe.Expire(ctx, trackingKey, trackingKeyTTL)
+
+	for _, data := range batch {
+		statsKey, visitorsKey, onlineKey := GetTodayKey("stats"), GetTodayKey("visitors"), "online_users"
+		if data.IsBot {
+			statsKey, visitorsKey, onlineKey = GetTodayKey("bot_stats"), GetTodayKey("bot_visitors"), "online_bots"
+		}
+
+		// 页面访问统计
+		pipe.HIncrBy(ctx, statsKey, "page_views", 1)
+		if !data.IsBot {
+			pipe.HIncrBy(ctx, statsKey, "path:"+data.Path, 1)
+			if data.EventType == "article_click" && data.ArticleID != nil {
+				pipe.HIncrBy(ctx, statsKey, fmt.Sprintf("article:%d", *data.ArticleID), 1)
+			}
+		}
+
+		// 独立访客，SAdd返回1时为今天的新访客
+		visitorAdds = append(visitorAdds, visitorAdd{
+			statsKey: statsKey,
+			cmd:      pipe.SAdd(ctx, visitorsKey, data.VisitorID),
+		})
+
+		// 在线用户，使用访客ID作为成员
+		pipe.ZAdd(ctx, onlineKey, redis.Z{
+			Score:  float64(now.Unix()),
+			Member: data.VisitorID,
+		})
+
+		pipe.Expire(ctx, statsKey, trackingKeyTTL)
+		pipe.Expire(ctx, visitorsKey, trackingKeyTTL)
+	}
+
+	// 清理30分钟前的在线记录
+	pipe.ZRemRangeByScore(ctx, "online_users", "0", expiredScore)
+	pipe.ZRemRangeByScore(ctx, "online_bots", "0", expiredScore)
+
+	// 同一批事件作为一条消息发布给实时推送连接
+	pipe.Publish(ctx, TrackingEventsChannel, liveMessage)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("存储追踪数据到Redis失败: %v", err)
 	}
 
-	// 清理30分钟前的记录
-	thirtyMinutesAgo := float64(time.Now().Add(-30 * time.Minute).Unix())
-	RedisClient.ZRemRangeByScore(ctx, key, "0", fmt.Sprintf("%.0f", thirtyMinutesAgo))
+	newVisitors := make(map[string]int64)
+	for _, add := range visitorAdds {
+		newVisitors[add.statsKey] += add.cmd.Val()
+	}
+	for statsKey, count := range newVisitors {
+		if count > 0 {
+			RedisClient.HIncrBy(ctx, statsKey, "unique_visitors", count)
+		}
+	}
 
 	return nil
 }
@@ -137,49 +237,19 @@ func GetOnlineUsersCount(ctx context.Context) (int64, error) {
 	return count, nil
 }
 
-// UpdatePageViewStats 更新页面访问统计
-func UpdatePageViewStats(ctx context.Context, path string, eventType string, articleID *uint) error {
-	todayKey := GetTodayKey("stats")
-	
-	// 总页面访问量
-	RedisClient.HIncrBy(ctx, todayKey, "page_views", 1)
-	
-	// 路径访问统计
-	pathKey := fmt.Sprintf("path:%s", path)
-	RedisClient.HIncrBy(ctx, todayKey, pathKey, 1)
-	
-	// 如果是文章点击，更新文章统计
-	if eventType == "article_click" && articleID != nil {
-		articleKey := fmt.Sprintf("article:%d", *articleID)
-		RedisClient.HIncrBy(ctx, todayKey, articleKey, 1)
-	}
-
-	// 设置过期时间
-	RedisClient.Expire(ctx, todayKey, 72*time.Hour)
-	
-	return nil
+// SubscribeTrackingEvents 订阅实时事件，每条消息是一批LiveTrackingEvent的JSON数组
+func SubscribeTrackingEvents(ctx context.Context) *redis.PubSub {
+	return RedisClient.Subscribe(ctx, TrackingEventsChannel)
 }
 
-// UpdateUniqueVisitors 更新独立访客统计
-func UpdateUniqueVisitors(ctx context.Context, ipAddress string) error {
-	todayKey := GetTodayKey("visitors")
-	
-	// 使用Redis Set存储今日访客IP
-	isNew, err := RedisClient.SAdd(ctx, todayKey, ipAddress).Result()
-	if err != nil {
-		return err
-	}
-
-	// 如果是新访客，更新统计
-	if isNew > 0 {
-		statsKey := GetTodayKey("stats")
-		RedisClient.HIncrBy(ctx, statsKey, "unique_visitors", 1)
-	}
+// GetTodayBotStats 获取今日机器人的统计数据
+func GetTodayBotStats(ctx context.Context) (map[string]string, error) {
+	return RedisClient.HGetAll(ctx, GetTodayKey("bot_stats")).Result()
+}
 
-	// 设置过期时间
-	RedisClient.Expire(ctx, todayKey, 72*time.Hour)
-	
-	return nil
+// GetOnlineBotsCount 获取在线机器人数量
+func GetOnlineBotsCount(ctx context.Context) (int64, error) {
+	return RedisClient.ZCard(ctx, "online_bots").Result()
 }
 
 // GetTodayStats 获取今日统计数据
@@ -0,0 +1,361 @@
+package utils
+
+import (
+	"blog-server/models"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// JSON Resume 文档结构，参考 https://jsonresume.org/schema
+type JSONResume struct {
+	Schema    string              `json:"$schema"`
+	Basics    JSONResumeBasics    `json:"basics"`
+	Work      []JSONResumeWork    `json:"work,omitempty"`
+	Education []JSONResumeEdu     `json:"education,omitempty"`
+	Skills    []JSONResumeSkill   `json:"skills,omitempty"`
+	Projects  []JSONResumeProject `json:"projects,omitempty"`
+	Meta      JSONResumeMeta      `json:"meta"`
+}
+
+type JSONResumeBasics struct {
+	Name     string              `json:"name"`
+	Label    string              `json:"label,omitempty"`
+	Image    string              `json:"image,omitempty"`
+	Email    string              `json:"email,omitempty"`
+	URL      string              `json:"url,omitempty"`
+	Summary  string              `json:"summary,omitempty"`
+	Location *JSONResumeLocation `json:"location,omitempty"`
+	Profiles []JSONResumeProfile `json:"profiles,omitempty"`
+}
+
+type JSONResumeLocation struct {
+	City string `json:"city,omitempty"`
+}
+
+type JSONResumeProfile struct {
+	Network  string `json:"network"`
+	Username string `json:"username,omitempty"`
+	URL      string `json:"url"`
+}
+
+type JSONResumeWork struct {
+	Name      string `json:"name"`
+	Position  string `json:"position,omitempty"`
+	Location  string `json:"location,omitempty"`
+	URL       string `json:"url,omitempty"`
+	StartDate string `json:"startDate,omitempty"`
+	EndDate   string `json:"endDate,omitempty"`
+	Summary   string `json:"summary,omitempty"`
+}
+
+type JSONResumeEdu struct {
+	Institution string `json:"institution"`
+	Area        string `json:"area,omitempty"`
+	StudyType   string `json:"studyType,omitempty"`
+	StartDate   string `json:"startDate,omitempty"`
+	EndDate     string `json:"endDate,omitempty"`
+}
+
+type JSONResumeSkill struct {
+	Name     string   `json:"name"`
+	Level    string   `json:"level,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+type JSONResumeProject struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	StartDate   string   `json:"startDate,omitempty"`
+	EndDate     string   `json:"endDate,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+}
+
+type JSONResumeMeta struct {
+	Version      string `json:"version"`
+	LastModified string `json:"lastModified"`
+}
+
+// BuildJSONResume 将资料转换为JSON Resume文档，资料需预加载结构化信息
+func BuildJSONResume(profile models.Profile) JSONResume {
+	resume := JSONResume{
+		Schema: "https://raw.githubusercontent.com/jsonresume/resume-schema/v1.0.0/schema.json",
+		Basics: JSONResumeBasics{
+			Name:    profile.Name,
+			Label:   profile.Position,
+			Image:   profile.Avatar,
+			Email:   profile.Email,
+			URL:     profile.Website,
+			Summary: profile.Bio,
+		},
+		Meta: JSONResumeMeta{
+			Version:      "v1.0.0",
+			LastModified: profile.UpdatedAt.UTC().Format("2006-01-02T15:04:05"),
+		},
+	}
+
+	if profile.Location != "" {
+		resume.Basics.Location = &JSONResumeLocation{City: profile.Location}
+	}
+
+	for _, social := range socialProfiles(profile) {
+		resume.Basics.Profiles = append(resume.Basics.Profiles, JSONResumeProfile{
+			Network:  social.network,
+			Username: usernameFromURL(social.url),
+			URL:      social.url,
+		})
+	}
+
+	for _, exp := range profile.Experiences {
+		resume.Work = append(resume.Work, JSONResumeWork{
+			Name:      exp.Company,
+			Position:  exp.Position,
+			Location:  exp.Location,
+			URL:       exp.URL,
+			StartDate: exp.StartDate,
+			EndDate:   exp.EndDate,
+			Summary:   exp.Description,
+		})
+	}
+
+	for _, edu := range profile.Educations {
+		resume.Education = append(resume.Education, JSONResumeEdu{
+			Institution: edu.Institution,
+			Area:        edu.Field,
+			StudyType:   edu.Degree,
+			StartDate:   edu.StartDate,
+			EndDate:     edu.EndDate,
+		})
+	}
+
+	for _, skill := range profile.SkillItems {
+		item := JSONResumeSkill{Name: skill.Name, Level: skill.Level}
+		if skill.Category != "" {
+			item.Keywords = []string{skill.Category}
+		}
+		resume.Skills = append(resume.Skills, item)
+	}
+
+	for _, project := range profile.Projects {
+		projectURL := project.URL
+		if projectURL == "" {
+			projectURL = project.RepoURL
+		}
+		resume.Projects = append(resume.Projects, JSONResumeProject{
+			Name:        project.Name,
+			Description: project.Description,
+			URL:         projectURL,
+			StartDate:   project.StartDate,
+			EndDate:     project.EndDate,
+			Keywords:    splitTechnologies(project.Technologies),
+		})
+	}
+
+	return resume
+}
+
+// BuildVCard 生成vCard 4.0（RFC 6350）文本
+func BuildVCard(profile models.Profile) string {
+	var lines []string
+	add := func(line string) {
+		lines = append(lines, foldVCardLine(line))
+	}
+
+	add("BEGIN:VCARD")
+	add("VERSION:4.0")
+	add("KIND:individual")
+	add("FN:" + escapeVCardText(profile.Name))
+	if profile.Email != "" {
+		add("EMAIL;TYPE=work:" + escapeVCardText(profile.Email))
+	}
+	if profile.Position != "" {
+		add("TITLE:" + escapeVCardText(profile.Position))
+	}
+	if profile.Company != "" {
+		add("ORG:" + escapeVCardText(profile.Company))
+	}
+	if profile.Location != "" {
+		// ADR各组成部分：邮箱;扩展地址;街道;城市;地区;邮编;国家
+		add("ADR:;;;" + escapeVCardText(profile.Location) + ";;;")
+	}
+	if profile.Avatar != "" {
+		add("PHOTO:" + profile.Avatar)
+	}
+	if profile.Website != "" {
+		add("URL:" + profile.Website)
+	}
+	for _, social := range socialProfiles(profile) {
+		add("URL;TYPE=" + strings.ToLower(social.network) + ":" + social.url)
+	}
+	if profile.Bio != "" {
+		add("NOTE:" + escapeVCardText(profile.Bio))
+	}
+	add("REV:" + profile.UpdatedAt.UTC().Format("20060102T150405Z"))
+	add("END:VCARD")
+
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// BuildPersonJSONLD 生成schema.org Person的JSON-LD，用于嵌入站点<head>
+func BuildPersonJSONLD(profile models.Profile) map[string]interface{} {
+	person := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "Person",
+		"name":     profile.Name,
+	}
+
+	if profile.Email != "" {
+		person["email"] = "mailto:" + profile.Email
+	}
+	if profile.Website != "" {
+		person["url"] = profile.Website
+	}
+	if profile.Avatar != "" {
+		person["image"] = profile.Avatar
+	}
+	if profile.Bio != "" {
+		person["description"] = profile.Bio
+	}
+	if profile.Position != "" {
+		person["jobTitle"] = profile.Position
+	}
+	if profile.Company != "" {
+		person["worksFor"] = map[string]interface{}{
+			"@type": "Organization",
+			"name":  profile.Company,
+		}
+	}
+	if profile.Location != "" {
+		person["address"] = map[string]interface{}{
+			"@type":           "PostalAddress",
+			"addressLocality": profile.Location,
+		}
+	}
+
+	var sameAs []string
+	for _, social := range socialProfiles(profile) {
+		sameAs = append(sameAs, social.url)
+	}
+	if len(sameAs) > 0 {
+		person["sameAs"] = sameAs
+	}
+
+	var knowsAbout []string
+	for _, skill := range profile.SkillItems {
+		knowsAbout = append(knowsAbout, skill.Name)
+	}
+	if len(knowsAbout) > 0 {
+		person["knowsAbout"] = knowsAbout
+	}
+
+	var alumniOf []map[string]interface{}
+	for _, edu := range profile.Educations {
+		alumniOf = append(alumniOf, map[string]interface{}{
+			"@type": "EducationalOrganization",
+			"name":  edu.Institution,
+		})
+	}
+	if len(alumniOf) > 0 {
+		person["alumniOf"] = alumniOf
+	}
+
+	return person
+}
+
+type socialProfile struct {
+	network string
+	url     string
+}
+
+// socialProfiles 收集资料中填写的社交链接
+func socialProfiles(profile models.Profile) []socialProfile {
+	var result []socialProfile
+	if profile.GitHub != "" {
+		result = append(result, socialProfile{"GitHub", profile.GitHub})
+	}
+	if profile.LinkedIn != "" {
+		result = append(result, socialProfile{"LinkedIn", profile.LinkedIn})
+	}
+	if profile.Twitter != "" {
+		result = append(result, socialProfile{"Twitter", profile.Twitter})
+	}
+	return result
+}
+
+// usernameFromURL 从社交主页链接中提取用户名，如 https://github.com/foo -> foo
+func usernameFromURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	username := parts[len(parts)-1]
+	// LinkedIn 形如 /in/foo，Twitter 可能带 @
+	return strings.TrimPrefix(username, "@")
+}
+
+// splitTechnologies 拆分逗号分隔的技术列表
+func splitTechnologies(technologies string) []string {
+	var result []string
+	for _, tech := range strings.Split(technologies, ",") {
+		if tech = strings.TrimSpace(tech); tech != "" {
+			result = append(result, tech)
+		}
+	}
+	return result
+}
+
+// escapeVCardText 按RFC 6350转义文本值
+func escapeVCardText(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		",", `\,`,
+		";", `\;`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return replacer.Replace(value)
+}
+
+// foldVCardLine 按RFC 6350将超过75字节的行折叠，不拆分多字节字符
+func foldVCardLine(line string) string {
+	const maxLen = 75
+	if len(line) <= maxLen {
+		return line
+	}
+
+	var b strings.Builder
+	lineLen := 0
+	limit := maxLen
+	for _, r := range line {
+		size := len(string(r))
+		if lineLen+size > limit {
+			b.WriteString("\r\n ")
+			lineLen = 0
+			// 续行开头的空格占一个字节
+			limit = maxLen - 1
+		}
+		b.WriteRune(r)
+		lineLen += size
+	}
+	return b.String()
+}
+
+// ProfileExportFileName 生成导出文件名
+func ProfileExportFileName(profile models.Profile, ext string) string {
+	// 只保留字母数字，避免文件名中出现特殊字符
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.Join(strings.Fields(profile.Name), "-")) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			b.WriteRune(r)
+		}
+	}
+
+	name := strings.Trim(b.String(), "-")
+	if name == "" {
+		name = "profile"
+	}
+	return name + "-" + time.Now().Format("20060102") + "." + ext
+}
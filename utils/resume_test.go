@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEscapeVCardText(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"普通文本", "Gopher", "Gopher"},
+		{"逗号和分号", "Go, Rust; C", `Go\, Rust\; C`},
+		{"反斜杠先转义", `C:\path,`, `C:\\path\,`},
+		{"换行", "第一行\n第二行", `第一行\n第二行`},
+		{"CRLF换行", "a\r\nb", `a\nb`},
+		{"空字符串", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeVCardText(tt.value); got != tt.want {
+				t.Errorf("escapeVCardText(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFoldVCardLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		lines int // 折叠后的行数
+	}{
+		{"短行不折叠", "FN:Gopher", 1},
+		{"正好75字节不折叠", strings.Repeat("a", 75), 1},
+		{"76字节折叠为两行", strings.Repeat("a", 76), 2},
+		{"续行包含开头的空格", strings.Repeat("a", 75+74+1), 3},
+		{"多字节字符", "NOTE:" + strings.Repeat("中文", 40), 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := foldVCardLine(tt.line)
+			lines := strings.Split(folded, "\r\n")
+			if len(lines) != tt.lines {
+				t.Fatalf("折叠为%d行，want %d: %q", len(lines), tt.lines, folded)
+			}
+
+			for i, line := range lines {
+				if len(line) > 75 {
+					t.Errorf("第%d行长度%d超过75字节", i+1, len(line))
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("第%d行拆分了多字节字符: %q", i+1, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("续行没有以空格开头: %q", line)
+				}
+			}
+
+			// 去掉折叠后应与原文相同
+			if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != tt.line {
+				t.Errorf("展开后 = %q, want %q", unfolded, tt.line)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // StartScheduler 启动定时任务
@@ -32,6 +33,45 @@ func StartScheduler() {
 	}()
 }
 
+// StartOrphanScanScheduler 启动孤立文件扫描定时任务
+// 每天凌晨3:30生成一份扫描报告，只报告不删除，由管理员确认后清理
+func StartOrphanScanScheduler() {
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day()+1, 3, 30, 0, 0, now.Location())
+			time.Sleep(next.Sub(now))
+
+			scan, err := ScanOrphans(models.OrphanScanScheduled)
+			if err != nil {
+				log.Printf("孤立文件扫描失败: %v", err)
+				continue
+			}
+			log.Printf("孤立文件扫描完成，共 %d 个对象，发现 %d 个孤立文件", scan.ObjectCount, scan.OrphanCount)
+		}
+	}()
+}
+
+// StartTusCleanupScheduler 启动断点续传清理任务
+// 每小时删除一次过期的上传会话及其分块
+func StartTusCleanupScheduler() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			cleaned, err := CleanupExpiredTusUploads()
+			if err != nil {
+				log.Printf("清理过期的断点续传失败: %v", err)
+				continue
+			}
+			if cleaned > 0 {
+				log.Printf("已清理 %d 个过期的断点续传", cleaned)
+			}
+		}
+	}()
+}
+
 // TransferDataToPostgreSQL 将Redis数据转存到PostgreSQL
 func TransferDataToPostgreSQL() error {
 	// 转存昨天的数据
@@ -56,22 +96,31 @@ func TransferSpecificDateData(date string) error {
 		return nil
 	}
 
-	// 2. 转存追踪事件到数据库
+	// 2. 补全旧数据的地理位置和访客ID，按会话行为补充识别机器人，再转存追踪事件到数据库
+	if err := prepareTrackingData(ctx, trackingDataList); err != nil {
+		return fmt.Errorf("处理追踪数据失败: %v", err)
+	}
+	markBehavioralBots(trackingDataList)
 	if err := storeTrackingEvents(trackingDataList); err != nil {
 		return fmt.Errorf("存储追踪事件失败: %v", err)
 	}
 
-	// 3. 生成并存储每日统计数据
+	// 3. 记录新访客的首次出现日期
+	if err := recordVisitorFirstSeen(date, trackingDataList); err != nil {
+		return fmt.Errorf("记录访客首次出现日期失败: %v", err)
+	}
+
+	// 4. 生成并存储每日统计数据
 	if err := generateDailyStats(date, trackingDataList); err != nil {
 		return fmt.Errorf("生成每日统计失败: %v", err)
 	}
 
-	// 4. 生成并存储页面热力图数据
+	// 5. 生成并存储页面热力图数据
 	if err := generatePageHeatmap(date, trackingDataList); err != nil {
 		return fmt.Errorf("生成页面热力图失败: %v", err)
 	}
 
-	// 5. 清理Redis中的数据
+	// 6. 清理Redis中的数据
 	if err := ClearTrackingDataForDate(ctx, date); err != nil {
 		log.Printf("清理Redis数据失败: %v", err) // 不作为致命错误
 	}
@@ -80,6 +129,33 @@ func TransferSpecificDateData(date string) error {
 	return nil
 }
 
+// prepareTrackingData 处理收集时缺少的字段
+// 旧版本写入Redis的数据包含原始IP，在这里补充地理位置、生成访客ID并按配置去掉IP，再按访问间隔重新划分会话
+func prepareTrackingData(ctx context.Context, trackingDataList []TrackingData) error {
+	var legacy []int
+	for i := range trackingDataList {
+		data := &trackingDataList[i]
+
+		// GeoIP启用前写入的数据在转存时补充地理位置
+		if data.Country == "" {
+			location := LookupGeo(data.IPAddress)
+			data.Country, data.City = location.Country, location.City
+		}
+
+		if data.VisitorID == "" {
+			visitorID, err := VisitorID(ctx, data.Timestamp.Format("2006-01-02"), data.IPAddress, data.UserAgent)
+			if err != nil {
+				return err
+			}
+			data.VisitorID = visitorID
+			data.IPAddress = StoredIPAddress(data.IPAddress)
+			legacy = append(legacy, i)
+		}
+	}
+	assignSessions(trackingDataList, legacy)
+	return nil
+}
+
 // storeTrackingEvents 存储追踪事件到数据库
 func storeTrackingEvents(trackingDataList []TrackingData) error {
 	var events []models.TrackingEvent
@@ -89,13 +165,29 @@ func storeTrackingEvents(trackingDataList []TrackingData) error {
 			Timestamp: data.Timestamp,
 			Path:      data.Path,
 			IPAddress: data.IPAddress,
+			VisitorID: data.VisitorID,
+			ClientID:  data.ClientID,
 			UserAgent: data.UserAgent,
 			Referer:   data.Referer,
 			EventType: data.EventType,
 			ArticleID: data.ArticleID,
 			SessionID: data.SessionID,
+			Country:   data.Country,
+			City:      data.City,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
+
+			Browser:        data.Browser,
+			BrowserVersion: data.BrowserVersion,
+			OS:             data.OS,
+			DeviceType:     data.DeviceType,
+
+			IsBot:     data.IsBot,
+			BotReason: data.BotReason,
+		}
+		if len(data.Properties) > 0 {
+			properties := string(data.Properties)
+			event.Properties = &properties
 		}
 		events = append(events, event)
 	}
@@ -108,6 +200,33 @@ func storeTrackingEvents(trackingDataList []TrackingData) error {
 	return nil
 }
 
+// recordVisitorFirstSeen 记录当天出现的客户端，已有记录的保持原来的首次出现日期
+func recordVisitorFirstSeen(date string, trackingDataList []TrackingData) error {
+	parsedDate, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	var visitors []models.VisitorFirstSeen
+	for _, data := range trackingDataList {
+		if data.IsBot || data.ClientID == "" || seen[data.ClientID] {
+			continue
+		}
+		seen[data.ClientID] = true
+		visitors = append(visitors, models.VisitorFirstSeen{
+			ClientID:  data.ClientID,
+			FirstSeen: parsedDate,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	if len(visitors) == 0 {
+		return nil
+	}
+	return models.DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(visitors, 100).Error
+}
+
 // generateDailyStats 生成每日统计数据
 func generateDailyStats(date string, trackingDataList []TrackingData) error {
 	parsedDate, err := time.Parse("2006-01-02", date)
@@ -116,15 +235,26 @@ func generateDailyStats(date string, trackingDataList []TrackingData) error {
 	}
 
 	// 统计数据
-	pageViews := int64(len(trackingDataList))
+	pageViews := int64(0)
 	uniqueVisitors := make(map[string]bool)
 	articleClicks := int64(0)
 	pathCounts := make(map[string]int64)
 	articleCounts := make(map[string]int64)
+	botPageViews := int64(0)
+	botVisitors := make(map[string]bool)
 
 	for _, data := range trackingDataList {
+		// 机器人单独计数，不计入访问统计
+		if data.IsBot {
+			botPageViews++
+			botVisitors[data.VisitorID] = true
+			continue
+		}
+
+		pageViews++
+
 		// 独立访客统计
-		uniqueVisitors[data.IPAddress] = true
+		uniqueVisitors[data.VisitorID] = true
 
 		// 文章点击统计
 		if data.EventType == "article_click" {
@@ -153,6 +283,8 @@ func generateDailyStats(date string, trackingDataList []TrackingData) error {
 				PageViews:      pageViews,
 				UniqueVisitors: int64(len(uniqueVisitors)),
 				ArticleClicks:  articleClicks,
+				BotPageViews:   botPageViews,
+				BotVisitors:    int64(len(botVisitors)),
 				TopPages:       string(topPagesJSON),
 				TopArticles:    string(topArticlesJSON),
 				CreatedAt:      time.Now(),
@@ -170,6 +302,8 @@ func generateDailyStats(date string, trackingDataList []TrackingData) error {
 		existingStats.PageViews = pageViews
 		existingStats.UniqueVisitors = int64(len(uniqueVisitors))
 		existingStats.ArticleClicks = articleClicks
+		existingStats.BotPageViews = botPageViews
+		existingStats.BotVisitors = int64(len(botVisitors))
 		existingStats.TopPages = string(topPagesJSON)
 		existingStats.TopArticles = string(topArticlesJSON)
 		existingStats.UpdatedAt = time.Now()
@@ -191,11 +325,16 @@ func generatePageHeatmap(date string, trackingDataList []TrackingData) error {
 
 	// 统计每个路径的访问和点击
 	pathStats := make(map[string]struct {
-		views  int64
-		clicks int64
+		views    int64
+		clicks   int64
+		visitors map[string]bool
 	})
 
 	for _, data := range trackingDataList {
+		if data.IsBot {
+			continue
+		}
+
 		stats := pathStats[data.Path]
 		stats.views++
 
@@ -203,12 +342,28 @@ func generatePageHeatmap(date string, trackingDataList []TrackingData) error {
 			stats.clicks++
 		}
 
+		if stats.visitors == nil {
+			stats.visitors = make(map[string]bool)
+		}
+		stats.visitors[data.VisitorID] = true
+
 		pathStats[data.Path] = stats
 	}
 
+	// 跳出率和停留时间需要按会话排序计算，使用已转存到数据库的事件
+	engagement, err := models.QueryPathEngagement(models.DB, date+" 00:00:00", date+" 23:59:59", false)
+	if err != nil {
+		return fmt.Errorf("计算跳出率和停留时间失败: %v", err)
+	}
+	engagementByPath := make(map[string]models.PathEngagement, len(engagement))
+	for _, e := range engagement {
+		engagementByPath[e.Path] = e
+	}
+
 	// 批量处理页面热力图数据
 	var heatmapData []models.PageHeatmap
 	for path, stats := range pathStats {
+		e := engagementByPath[path]
 		heatmap := models.PageHeatmap{
 			Date:      parsedDate,
 			Path:      path,
@@ -216,6 +371,13 @@ func generatePageHeatmap(date string, trackingDataList []TrackingData) error {
 			Views:     stats.views,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
+
+			UniqueVisitors:  int64(len(stats.visitors)),
+			Entries:         e.Entries,
+			Bounces:         e.Bounces,
+			BounceRate:      e.BounceRate(),
+			TimedViews:      e.TimedViews,
+			AverageStayTime: e.AverageStayTime,
 		}
 		heatmapData = append(heatmapData, heatmap)
 	}
@@ -4,53 +4,89 @@ import (
 	"blog-server/config"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"path/filepath"
 	"strings"
 	"time"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
 )
 
-type StorageService struct {
-	s3Client *s3.S3
+// ObjectInfo 存储对象信息
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type"`
+	LastModified time.Time `json:"last_modified"`
 }
 
-var Storage *StorageService
+// StorageBackend 存储后端接口
+type StorageBackend interface {
+	// PresignUpload 生成预签名上传URL，客户端直接PUT文件
+	// 签名绑定Content-Type和Content-Length，上传的文件必须与声明的大小一致
+	PresignUpload(key string, contentType string, size int64) (string, error)
+	// PresignDownload 生成有时效的下载URL，用于私有文件
+	PresignDownload(key string, expires time.Duration) (string, error)
+	// Put 上传对象
+	Put(key string, body io.Reader, size int64, contentType string) error
+	// Get 读取对象，调用方负责关闭返回的Reader
+	Get(key string) (io.ReadCloser, *ObjectInfo, error)
+	// Delete 删除对象
+	Delete(key string) error
+	// Stat 获取对象信息，对象不存在时返回ErrObjectNotFound
+	Stat(key string) (*ObjectInfo, error)
+	// List 列出指定前缀下的所有对象
+	List(prefix string) ([]ObjectInfo, error)
+	// PublicURL 生成公共访问URL
+	PublicURL(key string) string
+}
 
-// InitStorage 初始化存储服务
-func InitStorage() error {
-	sess, err := session.NewSession(&aws.Config{
-		Region:      aws.String("auto"),
-		Endpoint:    aws.String(config.AppConfig.R2Endpoint),
-		Credentials: credentials.NewStaticCredentials(config.AppConfig.R2AccessKeyID, config.AppConfig.R2SecretAccessKey, ""),
-	})
-	if err != nil {
-		return fmt.Errorf("创建AWS会话失败: %v", err)
-	}
+// ErrObjectNotFound 对象不存在
+var ErrObjectNotFound = errors.New("对象不存在")
 
-	Storage = &StorageService{
-		s3Client: s3.New(sess),
-	}
+// 存储驱动
+const (
+	StorageDriverS3     = "s3"
+	StorageDriverLocal  = "local"
+	StorageDriverMemory = "memory"
+)
 
-	return nil
-}
+// PrivateMediaPrefix 私有文件的对象键前缀，只能通过有时效的签名URL访问
+const PrivateMediaPrefix = "private/"
 
+var Storage StorageBackend
 
-// DeleteImage 删除R2中的图片
-func (s *StorageService) DeleteImage(fileName string) error {
-	_, err := s.s3Client.DeleteObject(&s3.DeleteObjectInput{
-		Bucket: aws.String(config.AppConfig.R2BucketName),
-		Key:    aws.String(fileName),
-	})
+// InitStorage 根据配置初始化存储服务
+func InitStorage() error {
+	var backend StorageBackend
+	var err error
+
+	switch config.AppConfig.StorageDriver {
+	case StorageDriverS3, "r2":
+		backend, err = NewS3Storage(
+			config.AppConfig.R2Endpoint,
+			config.AppConfig.R2AccessKeyID,
+			config.AppConfig.R2SecretAccessKey,
+			config.AppConfig.R2BucketName,
+			config.AppConfig.R2PublicURL,
+		)
+	case StorageDriverLocal:
+		backend, err = NewLocalStorage(
+			config.AppConfig.LocalStoragePath,
+			config.AppConfig.StoragePublicURL,
+			config.AppConfig.StorageSigningKey,
+		)
+	case StorageDriverMemory:
+		backend = NewMemoryStorage(config.AppConfig.StoragePublicURL, config.AppConfig.StorageSigningKey)
+	default:
+		return fmt.Errorf("不支持的存储驱动: %s", config.AppConfig.StorageDriver)
+	}
 
 	if err != nil {
-		return fmt.Errorf("删除文件失败: %v", err)
+		return err
 	}
 
+	Storage = backend
 	return nil
 }
 
@@ -58,12 +94,12 @@ func (s *StorageService) DeleteImage(fileName string) error {
 func generateFileName(originalName string) string {
 	ext := filepath.Ext(originalName)
 	timestamp := time.Now().Unix()
-	
+
 	// 生成随机字符串
 	randomBytes := make([]byte, 8)
 	rand.Read(randomBytes)
 	randomStr := hex.EncodeToString(randomBytes)
-	
+
 	return fmt.Sprintf("images/%d_%s%s", timestamp, randomStr, ext)
 }
 
@@ -71,7 +107,7 @@ func generateFileName(originalName string) string {
 func isValidImageType(filename string) bool {
 	ext := strings.ToLower(filepath.Ext(filename))
 	validTypes := []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
-	
+
 	for _, validType := range validTypes {
 		if ext == validType {
 			return true
@@ -83,7 +119,7 @@ func isValidImageType(filename string) bool {
 // getContentType 根据文件扩展名获取Content-Type
 func getContentType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
-	
+
 	switch ext {
 	case ".jpg", ".jpeg":
 		return "image/jpeg"
@@ -98,63 +134,40 @@ func getContentType(filename string) string {
 	}
 }
 
-// GeneratePresignedURL 生成预签名上传URL
-func (s *StorageService) GeneratePresignedURL(fileName string, contentType string) (string, error) {
-	req, _ := s.s3Client.PutObjectRequest(&s3.PutObjectInput{
-		Bucket:      aws.String(config.AppConfig.R2BucketName),
-		Key:         aws.String(fileName),
-		ContentType: aws.String(contentType),
-	})
-
-	// 设置过期时间为15分钟
-	url, err := req.Presign(15 * time.Minute)
-	if err != nil {
-		return "", fmt.Errorf("生成预签名URL失败: %v", err)
-	}
-
-	return url, nil
+// GenerateUniqueFileName 生成唯一文件名（给外部调用）
+func GenerateUniqueFileName(originalName string) string {
+	return generateFileName(originalName)
 }
 
-// GeneratePresignedDeleteURL 生成预签名删除URL
-func (s *StorageService) GeneratePresignedDeleteURL(fileName string) (string, error) {
-	req, _ := s.s3Client.DeleteObjectRequest(&s3.DeleteObjectInput{
-		Bucket: aws.String(config.AppConfig.R2BucketName),
-		Key:    aws.String(fileName),
-	})
-
-	// 设置过期时间为15分钟
-	url, err := req.Presign(15 * time.Minute)
-	if err != nil {
-		return "", fmt.Errorf("生成预签名删除URL失败: %v", err)
+// GenerateMediaKey 生成媒体文件的对象键，私有文件放在私有前缀下
+func GenerateMediaKey(originalName string, private bool) string {
+	if private {
+		return PrivateMediaPrefix + generateFileName(originalName)
 	}
+	return generateFileName(originalName)
+}
 
-	return url, nil
+// IsPrivateKey 对象键是否属于私有文件（与cleanObjectKey一致，忽略开头的斜杠）
+func IsPrivateKey(key string) bool {
+	return strings.HasPrefix(strings.TrimLeft(filepath.ToSlash(key), "/"), PrivateMediaPrefix)
 }
 
-// GenerateUniqueFileName 生成唯一文件名（给外部调用）
-func GenerateUniqueFileName(originalName string) string {
-	return generateFileName(originalName)
+// SignedDownloadURL 为私有文件生成有时效的下载URL，有效期由PRIVATE_URL_EXPIRY_MINUTES配置
+func SignedDownloadURL(key string) (string, error) {
+	return Storage.PresignDownload(key, time.Duration(config.AppConfig.PrivateURLExpiry)*time.Minute)
 }
 
 // GeneratePublicURL 生成公共访问URL
 func GeneratePublicURL(fileName string) string {
-	baseURL := strings.TrimRight(config.AppConfig.R2PublicURL, "/")
-	bucketName := config.AppConfig.R2BucketName
-	
-	// 如果baseURL已经包含了bucket名称，直接拼接
-	if strings.Contains(baseURL, bucketName) {
-		return fmt.Sprintf("%s/%s", baseURL, fileName)
-	}
-	
-	// 如果baseURL不包含bucket名称，则加上bucket名称
-	return fmt.Sprintf("%s/%s/%s", baseURL, bucketName, fileName)
+	return Storage.PublicURL(fileName)
 }
 
-// ExtractFileNameFromURL 从URL中提取文件名
-func ExtractFileNameFromURL(url string) string {
-	parts := strings.Split(url, "/")
-	if len(parts) > 0 {
-		return parts[len(parts)-1]
+// cleanObjectKey 规范化对象键，拒绝路径穿越
+func cleanObjectKey(key string) (string, error) {
+	key = strings.TrimLeft(filepath.ToSlash(key), "/")
+	cleaned := filepath.ToSlash(filepath.Clean(key))
+	if key == "" || cleaned == "." || cleaned != key || strings.HasPrefix(cleaned, "../") || cleaned == ".." {
+		return "", fmt.Errorf("无效的对象键: %s", key)
 	}
-	return ""
-}
\ No newline at end of file
+	return cleaned, nil
+}
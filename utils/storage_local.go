@@ -0,0 +1,302 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignedUploadStorage 由本服务路由接收上传和提供下载的存储后端（local、memory）
+type SignedUploadStorage interface {
+	StorageBackend
+	// VerifyUploadSignature 校验预签名上传URL中的参数
+	VerifyUploadSignature(key, contentType, size, expires, signature string) error
+	// VerifyDownloadSignature 校验有时效的下载URL中的参数
+	VerifyDownloadSignature(key, expires, signature string) error
+}
+
+// urlSigner 使用HMAC为本服务的存储路由生成和校验签名URL
+type urlSigner struct {
+	baseURL string
+	secret  []byte
+}
+
+// StorageRoutePrefix 本地存储文件的访问路由前缀
+const StorageRoutePrefix = "/storage/"
+
+func newURLSigner(baseURL, secret string) urlSigner {
+	return urlSigner{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  []byte(secret),
+	}
+}
+
+// objectURL 对象的访问地址
+func (s urlSigner) objectURL(key string) string {
+	return s.baseURL + StorageRoutePrefix + escapeKeyPath(key)
+}
+
+// uploadURL 生成带签名的上传地址，默认15分钟有效
+func (s urlSigner) uploadURL(key, contentType string, size int64) string {
+	expires := strconv.FormatInt(time.Now().Add(15*time.Minute).Unix(), 10)
+	sizeStr := strconv.FormatInt(size, 10)
+	query := url.Values{}
+	query.Set("content_type", contentType)
+	query.Set("size", sizeStr)
+	query.Set("expires", expires)
+	query.Set("signature", s.sign("PUT", key, contentType, sizeStr, expires))
+	return s.objectURL(key) + "?" + query.Encode()
+}
+
+// downloadURL 生成带签名的下载地址
+func (s urlSigner) downloadURL(key string, expires time.Duration) string {
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expiresAt)
+	query.Set("signature", s.sign("GET", key, expiresAt))
+	return s.objectURL(key) + "?" + query.Encode()
+}
+
+// verify 校验签名和有效期，fields为参与签名的字段，最后一个为过期时间
+func (s urlSigner) verify(signature string, fields ...string) error {
+	expiresAt, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return errors.New("无效的过期时间")
+	}
+	if time.Now().Unix() > expiresAt {
+		return errors.New("签名已过期")
+	}
+
+	expected := s.sign(fields...)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("签名无效")
+	}
+	return nil
+}
+
+func (s urlSigner) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// escapeKeyPath 对对象键逐段进行URL编码
+func escapeKeyPath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// LocalStorage 本地磁盘存储，文件通过Gin路由提供访问
+type LocalStorage struct {
+	urlSigner
+	root string
+}
+
+// NewLocalStorage 创建本地磁盘存储
+func NewLocalStorage(root, baseURL, secret string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("创建本地存储目录失败: %v", err)
+	}
+
+	return &LocalStorage{
+		urlSigner: newURLSigner(baseURL, secret),
+		root:      root,
+	}, nil
+}
+
+// PresignUpload 生成带HMAC签名的上传URL
+func (s *LocalStorage) PresignUpload(key string, contentType string, size int64) (string, error) {
+	if _, err := cleanObjectKey(key); err != nil {
+		return "", err
+	}
+	return s.uploadURL(key, contentType, size), nil
+}
+
+// VerifyUploadSignature 校验上传签名
+func (s *LocalStorage) VerifyUploadSignature(key, contentType, size, expires, signature string) error {
+	return s.verify(signature, "PUT", key, contentType, size, expires)
+}
+
+// PresignDownload 生成带HMAC签名的下载URL
+func (s *LocalStorage) PresignDownload(key string, expires time.Duration) (string, error) {
+	if _, err := cleanObjectKey(key); err != nil {
+		return "", err
+	}
+	return s.downloadURL(key, expires), nil
+}
+
+// VerifyDownloadSignature 校验下载签名
+func (s *LocalStorage) VerifyDownloadSignature(key, expires, signature string) error {
+	return s.verify(signature, "GET", key, expires)
+}
+
+// Put 写入文件，先写临时文件再重命名，避免读到不完整的文件
+func (s *LocalStorage) Put(key string, body io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("保存文件失败: %v", err)
+	}
+	return nil
+}
+
+// Get 读取文件，返回的Reader支持Seek
+func (s *LocalStorage) Get(key string) (io.ReadCloser, *ObjectInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, ErrObjectNotFound
+		}
+		return nil, nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	return file, s.objectInfo(key, stat), nil
+}
+
+// Delete 删除文件，文件不存在时不报错
+func (s *LocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("删除文件失败: %v", err)
+	}
+	return nil
+}
+
+// Stat 获取文件信息
+func (s *LocalStorage) Stat(key string) (*ObjectInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("获取文件信息失败: %v", err)
+	}
+	if stat.IsDir() {
+		return nil, ErrObjectNotFound
+	}
+
+	return s.objectInfo(key, stat), nil
+}
+
+// List 列出指定前缀下的文件
+func (s *LocalStorage) List(prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := filepath.WalkDir(s.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		stat, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, *s.objectInfo(key, stat))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("列出文件失败: %v", err)
+	}
+
+	return objects, nil
+}
+
+// PublicURL 生成公共访问URL
+func (s *LocalStorage) PublicURL(key string) string {
+	return s.objectURL(key)
+}
+
+// path 将对象键转换为磁盘路径
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned, err := cleanObjectKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *LocalStorage) objectInfo(key string, stat fs.FileInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Key:          key,
+		Size:         stat.Size(),
+		ContentType:  contentTypeForKey(key),
+		LastModified: stat.ModTime(),
+	}
+}
+
+// contentTypeForKey 根据扩展名推断Content-Type
+func contentTypeForKey(key string) string {
+	if contentType := getContentType(key); contentType != "application/octet-stream" {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
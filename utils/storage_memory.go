@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage 内存存储，用于测试和临时环境，进程重启后数据丢失
+type MemoryStorage struct {
+	urlSigner
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data         []byte
+	contentType  string
+	lastModified time.Time
+}
+
+// NewMemoryStorage 创建内存存储
+func NewMemoryStorage(baseURL, secret string) *MemoryStorage {
+	return &MemoryStorage{
+		urlSigner: newURLSigner(baseURL, secret),
+		objects:   make(map[string]memoryObject),
+	}
+}
+
+// PresignUpload 生成带HMAC签名的上传URL
+func (s *MemoryStorage) PresignUpload(key string, contentType string, size int64) (string, error) {
+	if _, err := cleanObjectKey(key); err != nil {
+		return "", err
+	}
+	return s.uploadURL(key, contentType, size), nil
+}
+
+// VerifyUploadSignature 校验上传签名
+func (s *MemoryStorage) VerifyUploadSignature(key, contentType, size, expires, signature string) error {
+	return s.verify(signature, "PUT", key, contentType, size, expires)
+}
+
+// PresignDownload 生成带HMAC签名的下载URL
+func (s *MemoryStorage) PresignDownload(key string, expires time.Duration) (string, error) {
+	if _, err := cleanObjectKey(key); err != nil {
+		return "", err
+	}
+	return s.downloadURL(key, expires), nil
+}
+
+// VerifyDownloadSignature 校验下载签名
+func (s *MemoryStorage) VerifyDownloadSignature(key, expires, signature string) error {
+	return s.verify(signature, "GET", key, expires)
+}
+
+// Put 保存对象
+func (s *MemoryStorage) Put(key string, body io.Reader, size int64, contentType string) error {
+	key, err := cleanObjectKey(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("读取上传内容失败: %v", err)
+	}
+	if contentType == "" {
+		contentType = contentTypeForKey(key)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = memoryObject{
+		data:         data,
+		contentType:  contentType,
+		lastModified: time.Now(),
+	}
+	return nil
+}
+
+// Get 读取对象，返回的Reader支持Seek
+func (s *MemoryStorage) Get(key string) (io.ReadCloser, *ObjectInfo, error) {
+	key, err := cleanObjectKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.RLock()
+	obj, ok := s.objects[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, nil, ErrObjectNotFound
+	}
+
+	return memoryReader{bytes.NewReader(obj.data)}, obj.info(key), nil
+}
+
+// memoryReader 为bytes.Reader补充Close方法
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (memoryReader) Close() error { return nil }
+
+// Delete 删除对象
+func (s *MemoryStorage) Delete(key string) error {
+	key, err := cleanObjectKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+// Stat 获取对象信息
+func (s *MemoryStorage) Stat(key string) (*ObjectInfo, error) {
+	key, err := cleanObjectKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return obj.info(key), nil
+}
+
+// List 列出指定前缀下的对象，按键排序
+func (s *MemoryStorage) List(prefix string) ([]ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var objects []ObjectInfo
+	for key, obj := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, *obj.info(key))
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+// PublicURL 生成公共访问URL
+func (s *MemoryStorage) PublicURL(key string) string {
+	return s.objectURL(key)
+}
+
+func (obj memoryObject) info(key string) *ObjectInfo {
+	return &ObjectInfo{
+		Key:          key,
+		Size:         int64(len(obj.data)),
+		ContentType:  obj.contentType,
+		LastModified: obj.lastModified,
+	}
+}
@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// S3Storage 基于S3协议的存储（Cloudflare R2）
+type S3Storage struct {
+	s3Client  *s3.S3
+	uploader  *s3manager.Uploader
+	bucket    string
+	publicURL string
+}
+
+// NewS3Storage 创建S3/R2存储
+func NewS3Storage(endpoint, accessKeyID, secretAccessKey, bucket, publicURL string) (*S3Storage, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("auto"),
+		Endpoint:    aws.String(endpoint),
+		Credentials: credentials.NewStaticCredentials(accessKeyID, secretAccessKey, ""),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建AWS会话失败: %v", err)
+	}
+
+	client := s3.New(sess)
+	return &S3Storage{
+		s3Client:  client,
+		uploader:  s3manager.NewUploaderWithClient(client),
+		bucket:    bucket,
+		publicURL: publicURL,
+	}, nil
+}
+
+// PresignUpload 生成预签名上传URL
+// Content-Length作为签名头，上传大小与声明不一致时R2会拒绝请求
+// R2不支持带content-length-range策略的POST表单上传，因此约定为精确大小而不是大小范围
+func (s *S3Storage) PresignUpload(key string, contentType string, size int64) (string, error) {
+	req, _ := s.s3Client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	})
+
+	// 设置过期时间为15分钟
+	url, err := req.Presign(15 * time.Minute)
+	if err != nil {
+		return "", fmt.Errorf("生成预签名URL失败: %v", err)
+	}
+
+	return url, nil
+}
+
+// PresignDownload 生成预签名下载URL，通过存储的API地址访问，不经过公共域名
+func (s *S3Storage) PresignDownload(key string, expires time.Duration) (string, error) {
+	req, _ := s.s3Client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+
+	url, err := req.Presign(expires)
+	if err != nil {
+		return "", fmt.Errorf("生成下载URL失败: %v", err)
+	}
+	return url, nil
+}
+
+// Put 流式上传对象
+func (s *S3Storage) Put(key string, body io.Reader, size int64, contentType string) error {
+	_, err := s.uploader.Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("上传文件失败: %v", err)
+	}
+	return nil
+}
+
+// Get 读取对象
+func (s *S3Storage) Get(key string) (io.ReadCloser, *ObjectInfo, error) {
+	output, err := s.s3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, nil, ErrObjectNotFound
+		}
+		return nil, nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(output.ContentLength),
+		ContentType:  aws.StringValue(output.ContentType),
+		LastModified: aws.TimeValue(output.LastModified),
+	}
+	return output.Body, info, nil
+}
+
+// Delete 删除对象
+func (s *S3Storage) Delete(key string) error {
+	_, err := s.s3Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+
+	if err != nil {
+		return fmt.Errorf("删除文件失败: %v", err)
+	}
+
+	return nil
+}
+
+// Stat 获取对象信息
+func (s *S3Storage) Stat(key string) (*ObjectInfo, error) {
+	output, err := s.s3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("获取文件信息失败: %v", err)
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(output.ContentLength),
+		ContentType:  aws.StringValue(output.ContentType),
+		LastModified: aws.TimeValue(output.LastModified),
+	}, nil
+}
+
+// List 列出指定前缀下的对象
+func (s *S3Storage) List(prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := s.s3Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.StringValue(obj.Key),
+				Size:         aws.Int64Value(obj.Size),
+				LastModified: aws.TimeValue(obj.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("列出文件失败: %v", err)
+	}
+
+	return objects, nil
+}
+
+// PublicURL 生成公共访问URL
+func (s *S3Storage) PublicURL(key string) string {
+	baseURL := strings.TrimRight(s.publicURL, "/")
+
+	// 如果baseURL已经包含了bucket名称，直接拼接
+	if strings.Contains(baseURL, s.bucket) {
+		return fmt.Sprintf("%s/%s", baseURL, key)
+	}
+
+	// 如果baseURL不包含bucket名称，则加上bucket名称
+	return fmt.Sprintf("%s/%s/%s", baseURL, s.bucket, key)
+}
+
+// isS3NotFound 判断是否为对象不存在错误
+func isS3NotFound(err error) bool {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanObjectKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"普通键", "media/2024/a.png", "media/2024/a.png", false},
+		{"去掉开头的斜杠", "/media/a.png", "media/a.png", false},
+		{"空键", "", "", true},
+		{"只有斜杠", "/", "", true},
+		{"当前目录", ".", "", true},
+		{"上级目录", "..", "", true},
+		{"跳出根目录", "../etc/passwd", "", true},
+		{"中间包含上级目录", "media/../../etc/passwd", "", true},
+		{"未规范化的路径", "media//a.png", "", true},
+		{"包含当前目录段", "media/./a.png", "", true},
+		{"末尾斜杠", "media/", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cleanObjectKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cleanObjectKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("cleanObjectKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+// signedQuery 解析签名URL，校验路径并返回查询参数
+func signedQuery(t *testing.T, rawURL, wantPath string) url.Values {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("解析URL失败: %v", err)
+	}
+	if u.Path != wantPath {
+		t.Fatalf("URL路径 = %q, want %q", u.Path, wantPath)
+	}
+	return u.Query()
+}
+
+func TestURLSignerUpload(t *testing.T) {
+	signer := newURLSigner("https://blog.example.com/", "secret")
+	query := signedQuery(t, signer.uploadURL("media/头像.png", "image/png", 1024),
+		"/storage/media/头像.png")
+
+	expires := query.Get("expires")
+	signature := query.Get("signature")
+	if query.Get("content_type") != "image/png" || query.Get("size") != "1024" {
+		t.Fatalf("上传参数不正确: %v", query)
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		contentType string
+		size        string
+		expires     string
+		signature   string
+		wantErr     bool
+	}{
+		{"签名有效", "media/头像.png", "image/png", "1024", expires, signature, false},
+		{"篡改对象键", "media/other.png", "image/png", "1024", expires, signature, true},
+		{"篡改内容类型", "media/头像.png", "text/html", "1024", expires, signature, true},
+		{"篡改大小", "media/头像.png", "image/png", "2048", expires, signature, true},
+		{"篡改过期时间", "media/头像.png", "image/png", "1024", expires + "0", signature, true},
+		{"签名为空", "media/头像.png", "image/png", "1024", expires, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := signer.verify(tt.signature, "PUT", tt.key, tt.contentType, tt.size, tt.expires)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestURLSignerDownload(t *testing.T) {
+	signer := newURLSigner("https://blog.example.com", "secret")
+	other := newURLSigner("https://blog.example.com", "other-secret")
+
+	query := signedQuery(t, signer.downloadURL("private/a.pdf", time.Hour), "/storage/private/a.pdf")
+	expires := query.Get("expires")
+	signature := query.Get("signature")
+
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	expiredSignature := signer.sign("GET", "private/a.pdf", past)
+
+	tests := []struct {
+		name      string
+		signer    urlSigner
+		key       string
+		expires   string
+		signature string
+		wantErr   string
+	}{
+		{"签名有效", signer, "private/a.pdf", expires, signature, ""},
+		{"密钥不同", other, "private/a.pdf", expires, signature, "签名无效"},
+		{"篡改对象键", signer, "private/b.pdf", expires, signature, "签名无效"},
+		{"已过期", signer, "private/a.pdf", past, expiredSignature, "签名已过期"},
+		{"过期时间无效", signer, "private/a.pdf", "abc", signature, "无效的过期时间"},
+		{"上传签名不能用于下载", signer, "private/a.pdf", expires,
+			signer.sign("PUT", "private/a.pdf", expires), "签名无效"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.signer.verify(tt.signature, "GET", tt.key, tt.expires)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("verify() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("verify() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMemoryStoragePresign(t *testing.T) {
+	storage := NewMemoryStorage("https://blog.example.com", "secret")
+
+	uploadURL, err := storage.PresignUpload("media/a.png", "image/png", 10)
+	if err != nil {
+		t.Fatalf("PresignUpload() error = %v", err)
+	}
+	query := signedQuery(t, uploadURL, "/storage/media/a.png")
+	if err := storage.VerifyUploadSignature("media/a.png", "image/png", "10",
+		query.Get("expires"), query.Get("signature")); err != nil {
+		t.Errorf("VerifyUploadSignature() error = %v", err)
+	}
+
+	downloadURL, err := storage.PresignDownload("media/a.png", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignDownload() error = %v", err)
+	}
+	query = signedQuery(t, downloadURL, "/storage/media/a.png")
+	if err := storage.VerifyDownloadSignature("media/a.png",
+		query.Get("expires"), query.Get("signature")); err != nil {
+		t.Errorf("VerifyDownloadSignature() error = %v", err)
+	}
+
+	for _, key := range []string{"", "../a.png", "media/../../a.png"} {
+		if _, err := storage.PresignUpload(key, "image/png", 10); err == nil {
+			t.Errorf("PresignUpload(%q) 应返回错误", key)
+		}
+		if _, err := storage.PresignDownload(key, time.Minute); err == nil {
+			t.Errorf("PresignDownload(%q) 应返回错误", key)
+		}
+	}
+}
+
+func TestMemoryStorageNormalizesKeys(t *testing.T) {
+	storage := NewMemoryStorage("https://blog.example.com", "secret")
+	if err := storage.Put("/media/a.png", strings.NewReader("png"), 3, "image/png"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// 写入和读取使用不同写法的同一对象键
+	for _, key := range []string{"media/a.png", "/media/a.png"} {
+		if _, err := storage.Stat(key); err != nil {
+			t.Errorf("Stat(%q) error = %v", key, err)
+		}
+		reader, _, err := storage.Get(key)
+		if err != nil {
+			t.Errorf("Get(%q) error = %v", key, err)
+			continue
+		}
+		reader.Close()
+	}
+
+	for _, key := range []string{"../media/a.png", "media/../../a.png", ""} {
+		if _, _, err := storage.Get(key); err == nil || err == ErrObjectNotFound {
+			t.Errorf("Get(%q) error = %v, want 无效的对象键", key, err)
+		}
+		if err := storage.Delete(key); err == nil {
+			t.Errorf("Delete(%q) 应返回错误", key)
+		}
+	}
+
+	if err := storage.Delete("/media/a.png"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := storage.Stat("media/a.png"); err != ErrObjectNotFound {
+		t.Errorf("删除后 Stat() error = %v, want ErrObjectNotFound", err)
+	}
+}
@@ -0,0 +1,289 @@
+package utils
+
+import (
+	"blog-server/config"
+	"blog-server/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// 数据收集被拒绝的原因，按原因分别计数
+const (
+	TrackRejectRateLimited  = "rate_limited"
+	TrackRejectSiteKey      = "site_key"
+	TrackRejectOrigin       = "origin"
+	TrackRejectInvalid      = "invalid_request"
+	TrackRejectEventType    = "event_type"
+	TrackRejectPath         = "path"
+	TrackRejectArticle      = "article"
+	TrackRejectProperties   = "properties"
+	TrackRejectRequestLimit = "request_limit" // 请求体过大或事件数超限
+)
+
+// maxTrackPathLength 上报路径的最大长度
+const maxTrackPathLength = 512
+
+// 已发布文章ID列表的刷新间隔，查不到文章时较早刷新，以便新发布的文章尽快生效
+const (
+	articleIDsRefreshInterval = time.Minute
+	articleIDsMissRefresh     = 10 * time.Second
+)
+
+// TrackRejection 数据收集的拒绝原因
+type TrackRejection struct {
+	Reason  string
+	Message string
+}
+
+func (r *TrackRejection) Error() string {
+	return r.Message
+}
+
+func rejectTracking(reason, format string, args ...interface{}) *TrackRejection {
+	return &TrackRejection{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// TrackRejectReason 取出错误对应的拒绝原因，其他错误视为请求不合法
+func TrackRejectReason(err error) string {
+	var rejection *TrackRejection
+	if errors.As(err, &rejection) {
+		return rejection.Reason
+	}
+	return TrackRejectInvalid
+}
+
+var eventTypePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// PropertyKeyPattern 事件属性键的格式
+var PropertyKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,40}$`)
+
+// trackTokenBucket 按IP的令牌桶，令牌按时间匀速补充，每个事件消耗一个令牌
+// 返回是否允许以及令牌不足时需要等待的毫秒数
+var trackTokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+local wait = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+else
+	wait = math.ceil((cost - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, wait}
+`)
+
+// AllowTrackingEvents 检查IP的令牌桶是否还能接收events个事件
+// 不允许时返回需要等待的时间；Redis出错时放行，不影响正常收集
+// 令牌桶的键使用IP的HMAC，不出现原始IP
+func AllowTrackingEvents(ctx context.Context, ipAddress string, events int) (bool, time.Duration) {
+	perMinute, burst := config.AppConfig.TrackRatePerMinute, config.AppConfig.TrackRateBurst
+	if perMinute <= 0 || burst <= 0 {
+		return true, 0
+	}
+
+	// 单次请求的事件数超过桶容量时按桶容量计算，避免永远无法通过
+	cost := min(int64(events), burst)
+	rate := float64(perMinute) / 60
+
+	key := IPCounterKey(ctx, "track_bucket", ipAddress)
+	if key == "" {
+		return true, 0
+	}
+	result, err := trackTokenBucket.Run(ctx, RedisClient, []string{key},
+		strconv.FormatFloat(rate, 'f', -1, 64), burst, time.Now().UnixMilli(), cost).Int64Slice()
+	if err != nil || len(result) != 2 {
+		return true, 0
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond
+}
+
+// VerifyTrackingSource 校验站点密钥和请求来源
+// 站点密钥通过X-Site-Key请求头或site_key参数传递（sendBeacon无法设置请求头）
+func VerifyTrackingSource(c *gin.Context) error {
+	if siteKey := config.AppConfig.TrackSiteKey; siteKey != "" {
+		provided := c.GetHeader("X-Site-Key")
+		if provided == "" {
+			provided = c.Query("site_key")
+		}
+		if provided != siteKey {
+			return rejectTracking(TrackRejectSiteKey, "站点密钥无效")
+		}
+	}
+
+	if origins := config.AppConfig.TrackAllowedOrigins; len(origins) > 0 {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			// 同源的GET请求和部分浏览器的sendBeacon不带Origin，改用Referer的来源
+			if referer, err := url.Parse(c.GetHeader("Referer")); err == nil && referer.Host != "" {
+				origin = referer.Scheme + "://" + referer.Host
+			}
+		}
+		if !slices.Contains(origins, strings.TrimSuffix(origin, "/")) {
+			return rejectTracking(TrackRejectOrigin, "不允许的来源: %s", origin)
+		}
+	}
+
+	return nil
+}
+
+// ValidateTrackingEvent 校验事件类型、路径和文章ID
+func ValidateTrackingEvent(eventType, trackPath string, articleID *uint) error {
+	if !eventTypePattern.MatchString(eventType) || !slices.Contains(config.AppConfig.TrackEventTypes, eventType) {
+		return rejectTracking(TrackRejectEventType, "不支持的事件类型: %s", eventType)
+	}
+
+	if err := validateTrackingPath(trackPath); err != nil {
+		return err
+	}
+
+	if articleID != nil && !isPublishedArticle(*articleID) {
+		return rejectTracking(TrackRejectArticle, "文章不存在: %d", *articleID)
+	}
+	return nil
+}
+
+// ValidateTrackingProperties 校验事件属性并序列化，没有属性时返回nil
+// 属性必须是一层的对象，值只能是字符串、数字或布尔值，键数和大小受配置限制
+func ValidateTrackingProperties(properties map[string]interface{}) (json.RawMessage, error) {
+	if len(properties) == 0 {
+		return nil, nil
+	}
+
+	if maxKeys := config.AppConfig.TrackPropertiesMaxKeys; int64(len(properties)) > maxKeys {
+		return nil, rejectTracking(TrackRejectProperties, "属性最多%d个", maxKeys)
+	}
+	for key, value := range properties {
+		if !PropertyKeyPattern.MatchString(key) {
+			return nil, rejectTracking(TrackRejectProperties, "属性名格式错误: %s", key)
+		}
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return nil, rejectTracking(TrackRejectProperties, "属性%s的值只能是字符串、数字或布尔值", key)
+		}
+	}
+
+	encoded, err := json.Marshal(properties)
+	if err != nil {
+		return nil, rejectTracking(TrackRejectProperties, "属性格式错误")
+	}
+	if maxBytes := config.AppConfig.TrackPropertiesMaxBytes; int64(len(encoded)) > maxBytes {
+		return nil, rejectTracking(TrackRejectProperties, "属性超过%d字节", maxBytes)
+	}
+	return encoded, nil
+}
+
+// validateTrackingPath 检查路径格式，并且必须匹配TRACK_ALLOWED_PATHS中的一个模式（配置为空时只检查格式）
+// 模式中的*匹配一段路径，以/**结尾的模式匹配该前缀下的所有路径
+func validateTrackingPath(trackPath string) error {
+	if len(trackPath) > maxTrackPathLength || !strings.HasPrefix(trackPath, "/") {
+		return rejectTracking(TrackRejectPath, "路径格式错误")
+	}
+	for _, r := range trackPath {
+		if r <= ' ' || r == 0x7f {
+			return rejectTracking(TrackRejectPath, "路径格式错误")
+		}
+	}
+
+	patterns := config.AppConfig.TrackAllowedPaths
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	// 只按路径部分匹配，忽略查询参数和锚点
+	routePath := trackPath
+	if i := strings.IndexAny(routePath, "?#"); i >= 0 {
+		routePath = routePath[:i]
+	}
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if routePath == prefix || strings.HasPrefix(routePath, prefix+"/") {
+				return nil
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, routePath); matched {
+			return nil
+		}
+	}
+	return rejectTracking(TrackRejectPath, "不允许的路径: %s", routePath)
+}
+
+var publishedArticles struct {
+	sync.Mutex
+	ids       map[uint]bool
+	refreshed time.Time
+}
+
+// isPublishedArticle 检查文章是否已发布，已发布文章的ID列表定期从数据库刷新
+func isPublishedArticle(id uint) bool {
+	publishedArticles.Lock()
+	defer publishedArticles.Unlock()
+
+	age := time.Since(publishedArticles.refreshed)
+	if age > articleIDsRefreshInterval || (!publishedArticles.ids[id] && age > articleIDsMissRefresh) {
+		var ids []uint
+		if err := models.DB.Model(&models.Article{}).Where("status = ?", "published").Pluck("id", &ids).Error; err == nil {
+			publishedArticles.ids = make(map[uint]bool, len(ids))
+			for _, articleID := range ids {
+				publishedArticles.ids[articleID] = true
+			}
+		}
+		// 查询失败时沿用旧列表，一分钟后再重试
+		publishedArticles.refreshed = time.Now()
+	}
+
+	// 还没有成功加载过列表时不拒绝
+	if publishedArticles.ids == nil {
+		return true
+	}
+	return publishedArticles.ids[id]
+}
+
+// RecordTrackingRejections 按原因累计当天被拒绝的事件数
+func RecordTrackingRejections(ctx context.Context, reason string, events int) {
+	key := GetTodayKey("track_rejected")
+	pipe := RedisClient.Pipeline()
+	pipe.HIncrBy(ctx, key, reason, int64(events))
+	pipe.Expire(ctx, key, 30*24*time.Hour)
+	pipe.Exec(ctx)
+}
+
+// GetTrackingRejections 获取指定日期各原因被拒绝的事件数
+func GetTrackingRejections(ctx context.Context, date string) (map[string]int64, error) {
+	values, err := RedisClient.HGetAll(ctx, "track_rejected:"+date).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(values))
+	for reason, value := range values {
+		counts[reason], _ = strconv.ParseInt(value, 10, 64)
+	}
+	return counts, nil
+}
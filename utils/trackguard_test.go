@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"blog-server/config"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidateTrackingProperties(t *testing.T) {
+	config.AppConfig = &config.Config{
+		TrackPropertiesMaxKeys:  3,
+		TrackPropertiesMaxBytes: 64,
+	}
+
+	tests := []struct {
+		name       string
+		properties map[string]interface{}
+		want       string
+		wantErr    bool
+	}{
+		{"没有属性", nil, "", false},
+		{"空对象", map[string]interface{}{}, "", false},
+		{"字符串数字和布尔值", map[string]interface{}{"plan": "pro", "price": 9.9, "trial": true}, `{"plan":"pro","price":9.9,"trial":true}`, false},
+		{"正好达到键数上限", map[string]interface{}{"a": "1", "b": "2", "c": "3"}, `{"a":"1","b":"2","c":"3"}`, false},
+		{"超过键数上限", map[string]interface{}{"a": "1", "b": "2", "c": "3", "d": "4"}, "", true},
+		{"属性名包含非法字符", map[string]interface{}{"plan-name": "pro"}, "", true},
+		{"属性名过长", map[string]interface{}{strings.Repeat("a", 41): "x"}, "", true},
+		{"属性名为空", map[string]interface{}{"": "x"}, "", true},
+		{"嵌套对象", map[string]interface{}{"user": map[string]interface{}{"id": 1.0}}, "", true},
+		{"数组", map[string]interface{}{"tags": []interface{}{"go"}}, "", true},
+		{"null值", map[string]interface{}{"plan": nil}, "", true},
+		{"超过大小上限", map[string]interface{}{"note": strings.Repeat("x", 64)}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateTrackingProperties(tt.properties)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ValidateTrackingProperties() = %s, want error", got)
+				}
+				if reason := TrackRejectReason(err); reason != TrackRejectProperties {
+					t.Errorf("拒绝原因 = %q, want %q", reason, TrackRejectProperties)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateTrackingProperties() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ValidateTrackingProperties() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackRejectReason(t *testing.T) {
+	if reason := TrackRejectReason(fmt.Errorf("包装: %w", rejectTracking(TrackRejectPath, "路径格式错误"))); reason != TrackRejectPath {
+		t.Errorf("TrackRejectReason() = %q, want %q", reason, TrackRejectPath)
+	}
+	if reason := TrackRejectReason(fmt.Errorf("其他错误")); reason != TrackRejectInvalid {
+		t.Errorf("TrackRejectReason() = %q, want %q", reason, TrackRejectInvalid)
+	}
+}
@@ -0,0 +1,263 @@
+package utils
+
+import (
+	"blog-server/config"
+	"blog-server/models"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// tus协议版本和支持的扩展
+const (
+	TusVersion    = "1.0.0"
+	TusExtensions = "creation,termination,expiration"
+)
+
+// tusChunkPrefix 断点续传分块的对象前缀，不在孤立文件扫描范围内，由过期清理任务负责
+const tusChunkPrefix = "tus/"
+
+var (
+	// ErrTusOffsetMismatch 分块的起始位置与已接收的字节数不一致
+	ErrTusOffsetMismatch = errors.New("Upload-Offset与已接收的字节数不一致")
+	// ErrTusIncomplete 分块不完整，无法合并
+	ErrTusIncomplete = errors.New("上传的分块不完整")
+)
+
+// tusLockDuration 接收分块时锁定上传会话的最长时间
+const tusLockDuration = 15 * time.Minute
+
+// NewTusUploadID 生成上传会话ID
+func NewTusUploadID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// TusExpiresAt 从现在起算的上传会话过期时间
+func TusExpiresAt() time.Time {
+	return time.Now().Add(time.Duration(config.AppConfig.TusUploadExpiry) * time.Hour)
+}
+
+// ParseTusMetadata 解析Upload-Metadata请求头
+// 格式为逗号分隔的键值对，值使用Base64编码，可以省略
+func ParseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("Upload-Metadata中%s的值不是有效的Base64", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// LockTusUpload 锁定上传会话，同一上传同时只接收一个分块，已被锁定时返回false
+// 锁通过数据库的条件更新获取，多实例部署时同样有效；获取成功后重新读取会话，保证已接收的字节数是最新的
+func LockTusUpload(upload *models.TusUpload) (unlock func(), ok bool) {
+	token := NewTusUploadID()
+	now := time.Now()
+	result := models.DB.Model(&models.TusUpload{}).
+		Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", upload.ID, now).
+		Updates(map[string]interface{}{
+			"lock_token":   token,
+			"locked_until": now.Add(tusLockDuration),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, false
+	}
+
+	unlock = func() {
+		models.DB.Model(&models.TusUpload{}).
+			Where("id = ? AND lock_token = ?", upload.ID, token).
+			Updates(map[string]interface{}{
+				"lock_token":   "",
+				"locked_until": nil,
+			})
+	}
+	if err := models.DB.Where("id = ?", upload.ID).First(upload).Error; err != nil {
+		unlock()
+		return nil, false
+	}
+	return unlock, true
+}
+
+// tusChunkKey 分块的对象键，按起始位置补零便于排序
+func tusChunkKey(id string, offset int64) string {
+	return fmt.Sprintf("%s%s/%020d", tusChunkPrefix, id, offset)
+}
+
+// WriteTusChunk 保存一个分块并更新已接收的字节数
+// 请求体先写入临时文件，连接中断时已收到的部分仍会保存，客户端可从新的位置继续上传
+func WriteTusChunk(upload *models.TusUpload, body io.Reader) (int64, error) {
+	remaining := upload.Length - upload.Offset
+
+	tmp, err := os.CreateTemp("", "tus-*")
+	if err != nil {
+		return 0, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	n, readErr := io.Copy(tmp, io.LimitReader(body, remaining+1))
+	if n > remaining {
+		return 0, ErrFileTooLarge
+	}
+	if n == 0 {
+		return 0, readErr
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	if err := Storage.Put(tusChunkKey(upload.ID, upload.Offset), tmp, n, "application/octet-stream"); err != nil {
+		return 0, err
+	}
+
+	expiresAt := TusExpiresAt()
+	result := models.DB.Model(&models.TusUpload{}).
+		Where("id = ? AND upload_offset = ?", upload.ID, upload.Offset).
+		Updates(map[string]interface{}{
+			"upload_offset": gorm.Expr("upload_offset + ?", n),
+			"expires_at":    expiresAt,
+		})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrTusOffsetMismatch
+	}
+
+	upload.Offset += n
+	upload.ExpiresAt = expiresAt
+	return n, nil
+}
+
+// OpenTusUpload 按顺序读取所有分块，返回合并后的内容
+func OpenTusUpload(upload *models.TusUpload) (io.ReadCloser, error) {
+	objects, err := Storage.List(tusChunkPrefix + upload.ID + "/")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	// 中断后重传的分块会覆盖同一起始位置的对象，这里只需检查分块首尾相接
+	var keys []string
+	var offset int64
+	for _, object := range objects {
+		start, err := strconv.ParseInt(object.Key[strings.LastIndex(object.Key, "/")+1:], 10, 64)
+		if err != nil || start != offset {
+			continue
+		}
+		keys = append(keys, object.Key)
+		offset += object.Size
+	}
+	if offset != upload.Length {
+		return nil, ErrTusIncomplete
+	}
+
+	return &tusChunkReader{keys: keys}, nil
+}
+
+// tusChunkReader 依次打开并读取各个分块
+type tusChunkReader struct {
+	keys    []string
+	current io.ReadCloser
+}
+
+func (r *tusChunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			reader, _, err := Storage.Get(r.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			r.current = reader
+			r.keys = r.keys[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *tusChunkReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+// DeleteTusChunks 删除上传会话的所有分块
+func DeleteTusChunks(id string) error {
+	objects, err := Storage.List(tusChunkPrefix + id + "/")
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := Storage.Delete(object.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteTusUpload 删除上传会话及其分块，已登记的媒体记录不受影响
+func DeleteTusUpload(upload *models.TusUpload) error {
+	if err := DeleteTusChunks(upload.ID); err != nil {
+		return err
+	}
+	return models.DB.Delete(upload).Error
+}
+
+// CleanupExpiredTusUploads 清理过期的上传会话，返回清理的数量
+// 正在接收分块的会话跳过，下次再清理
+func CleanupExpiredTusUploads() (int, error) {
+	var uploads []models.TusUpload
+	if err := models.DB.Where("expires_at < ?", time.Now()).Find(&uploads).Error; err != nil {
+		return 0, err
+	}
+
+	cleaned := 0
+	for i := range uploads {
+		unlock, ok := LockTusUpload(&uploads[i])
+		if !ok {
+			continue
+		}
+		err := DeleteTusUpload(&uploads[i])
+		unlock()
+		if err != nil {
+			return cleaned, err
+		}
+		cleaned++
+	}
+	return cleaned, nil
+}
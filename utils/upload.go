@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"blog-server/config"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// SniffLength 内容嗅探需要读取的字节数
+const SniffLength = 512
+
+// allowedImageTypes 允许上传的图片类型及对应扩展名
+var allowedImageTypes = map[string][]string{
+	"image/jpeg": {".jpg", ".jpeg"},
+	"image/png":  {".png"},
+	"image/gif":  {".gif"},
+	"image/webp": {".webp"},
+}
+
+// allowedVideoTypes 允许通过断点续传上传的视频类型及对应扩展名
+var allowedVideoTypes = map[string][]string{
+	"video/mp4":  {".mp4", ".m4v"},
+	"video/webm": {".webm"},
+}
+
+// ErrFileTooLarge 文件超过大小限制
+var ErrFileTooLarge = errors.New("文件超过大小限制")
+
+// NormalizeContentType 规范化Content-Type，去掉参数并统一别名
+func NormalizeContentType(contentType string) string {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = strings.TrimSpace(contentType[:i])
+	}
+	if contentType == "image/jpg" || contentType == "image/pjpeg" {
+		return "image/jpeg"
+	}
+	return contentType
+}
+
+// ValidateImageDeclaration 校验客户端声明的文件名和类型（用于预签名上传，无法读取内容）
+func ValidateImageDeclaration(fileName, contentType string) error {
+	contentType = NormalizeContentType(contentType)
+	extensions, ok := allowedImageTypes[contentType]
+	if !ok {
+		return fmt.Errorf("不支持的文件类型: %s", contentType)
+	}
+	if !hasExtension(fileName, extensions) {
+		return errors.New("文件扩展名与文件类型不匹配")
+	}
+	return nil
+}
+
+// ValidateImageContent 根据文件头的魔数识别真实类型，并与文件名、声明的类型比对
+// 返回嗅探出的Content-Type
+func ValidateImageContent(fileName, declaredType string, head []byte) (string, error) {
+	sniffed := NormalizeContentType(http.DetectContentType(head))
+	extensions, ok := allowedImageTypes[sniffed]
+	if !ok {
+		return "", fmt.Errorf("不支持的文件内容类型: %s", sniffed)
+	}
+
+	if !hasExtension(fileName, extensions) {
+		return "", errors.New("文件扩展名与文件内容不匹配")
+	}
+
+	// application/octet-stream 表示客户端未声明具体类型
+	declaredType = NormalizeContentType(declaredType)
+	if declaredType != "" && declaredType != "application/octet-stream" && declaredType != sniffed {
+		return "", fmt.Errorf("声明的类型%s与文件内容%s不匹配", declaredType, sniffed)
+	}
+
+	return sniffed, nil
+}
+
+// ValidateMediaDeclaration 校验断点续传声明的文件名和类型，允许图片和视频
+func ValidateMediaDeclaration(fileName, contentType string) error {
+	contentType = NormalizeContentType(contentType)
+	if _, ok := allowedVideoTypes[contentType]; !ok {
+		return ValidateImageDeclaration(fileName, contentType)
+	}
+	if !hasExtension(fileName, allowedVideoTypes[contentType]) {
+		return errors.New("文件扩展名与文件类型不匹配")
+	}
+	return nil
+}
+
+// ValidateMediaContent 按文件头校验断点续传的文件，允许图片和视频
+func ValidateMediaContent(fileName, declaredType string, head []byte) (string, error) {
+	sniffed := NormalizeContentType(http.DetectContentType(head))
+	extensions, ok := allowedVideoTypes[sniffed]
+	if !ok {
+		return ValidateImageContent(fileName, declaredType, head)
+	}
+
+	if !hasExtension(fileName, extensions) {
+		return "", errors.New("文件扩展名与文件内容不匹配")
+	}
+	declaredType = NormalizeContentType(declaredType)
+	if declaredType != "" && declaredType != "application/octet-stream" && declaredType != sniffed {
+		return "", fmt.Errorf("声明的类型%s与文件内容%s不匹配", declaredType, sniffed)
+	}
+	return sniffed, nil
+}
+
+// MaxUploadSizeFor 返回指定类型文件的大小上限，视频使用单独的上限
+func MaxUploadSizeFor(contentType string) int64 {
+	if _, ok := allowedVideoTypes[NormalizeContentType(contentType)]; ok {
+		return config.AppConfig.MaxVideoUploadSize
+	}
+	return config.AppConfig.MaxUploadSize
+}
+
+// ReadHead 读取用于嗅探的文件头，返回读取的内容和可继续读取完整内容的Reader
+func ReadHead(r io.Reader) ([]byte, io.Reader, error) {
+	head := make([]byte, SniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, nil, err
+	}
+	head = head[:n]
+	return head, io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// SizeLimitReader 限制读取总字节数，超出时返回ErrFileTooLarge，并记录已读取的字节数
+type SizeLimitReader struct {
+	R     io.Reader
+	Limit int64
+	N     int64
+}
+
+func (l *SizeLimitReader) Read(p []byte) (int, error) {
+	n, err := l.R.Read(p)
+	l.N += int64(n)
+	if l.N > l.Limit {
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
+
+// hasExtension 检查文件扩展名（不区分大小写）
+func hasExtension(fileName string, extensions []string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, allowed := range extensions {
+		if ext == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package utils
+
+import "testing"
+
+// 各类型文件头的魔数
+var (
+	pngHead  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	jpegHead = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")
+	gifHead  = []byte("GIF89a\x01\x00\x01\x00")
+	webpHead = []byte("RIFF\x24\x00\x00\x00WEBPVP8 ")
+	htmlHead = []byte("<!DOCTYPE html><html><script>alert(1)</script>")
+	svgHead  = []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`)
+)
+
+func TestValidateImageContent(t *testing.T) {
+	tests := []struct {
+		name         string
+		fileName     string
+		declaredType string
+		head         []byte
+		want         string
+		wantErr      bool
+	}{
+		{"PNG", "a.png", "image/png", pngHead, "image/png", false},
+		{"JPEG扩展名jpg", "a.jpg", "image/jpeg", jpegHead, "image/jpeg", false},
+		{"JPEG扩展名大写", "A.JPEG", "image/jpeg", jpegHead, "image/jpeg", false},
+		{"声明image/jpg别名", "a.jpg", "image/jpg", jpegHead, "image/jpeg", false},
+		{"GIF", "a.gif", "image/gif", gifHead, "image/gif", false},
+		{"WebP", "a.webp", "image/webp", webpHead, "image/webp", false},
+		{"未声明类型", "a.png", "", pngHead, "image/png", false},
+		{"声明octet-stream", "a.png", "application/octet-stream", pngHead, "image/png", false},
+		{"声明类型带参数", "a.png", "image/png; charset=binary", pngHead, "image/png", false},
+		{"HTML伪装成PNG", "a.png", "image/png", htmlHead, "", true},
+		{"SVG不允许上传", "a.svg", "image/svg+xml", svgHead, "", true},
+		{"扩展名与内容不匹配", "a.gif", "image/png", pngHead, "", true},
+		{"没有扩展名", "a", "image/png", pngHead, "", true},
+		{"声明类型与内容不匹配", "a.png", "image/gif", pngHead, "", true},
+		{"空文件", "a.png", "image/png", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateImageContent(tt.fileName, tt.declaredType, tt.head)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateImageContent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateImageContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/mssola/useragent"
+)
+
+// 设备类型
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+)
+
+// UserAgentInfo 从User-Agent解析出的浏览器、系统和设备类型
+type UserAgentInfo struct {
+	Browser        string `json:"browser"`
+	BrowserVersion string `json:"browser_version"` // 只保留主版本号
+	OS             string `json:"os"`
+	DeviceType     string `json:"device_type"`
+}
+
+// ParseUserAgent 解析User-Agent，原始字符串不会被保存
+func ParseUserAgent(raw string) UserAgentInfo {
+	if strings.TrimSpace(raw) == "" {
+		return UserAgentInfo{DeviceType: DeviceBot}
+	}
+
+	ua := useragent.New(raw)
+	name, version := ua.Browser()
+	if major, _, found := strings.Cut(version, "."); found {
+		version = major
+	}
+
+	info := UserAgentInfo{
+		Browser:        name,
+		BrowserVersion: version,
+		OS:             osName(ua),
+		DeviceType:     DeviceDesktop,
+	}
+
+	switch {
+	case ua.Bot():
+		info.DeviceType = DeviceBot
+	case isTabletUserAgent(raw):
+		info.DeviceType = DeviceTablet
+	case ua.Mobile():
+		info.DeviceType = DeviceMobile
+	}
+	return info
+}
+
+// osName 操作系统名称，iPhone和iPad统一为iOS
+func osName(ua *useragent.UserAgent) string {
+	switch ua.Platform() {
+	case "iPhone", "iPad", "iPod", "iPod touch":
+		return "iOS"
+	}
+	return ua.OSInfo().Name
+}
+
+// isTabletUserAgent 识别平板设备，Android平板的UA中不含Mobile
+func isTabletUserAgent(raw string) bool {
+	lower := strings.ToLower(raw)
+	if strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet") {
+		return true
+	}
+	return strings.Contains(lower, "android") && !strings.Contains(lower, "mobile")
+}
@@ -0,0 +1,60 @@
+package utils
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want UserAgentInfo
+	}{
+		{
+			"Windows Chrome",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.130 Safari/537.36",
+			UserAgentInfo{Browser: "Chrome", BrowserVersion: "120", OS: "Windows", DeviceType: DeviceDesktop},
+		},
+		{
+			"macOS Firefox",
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:121.0) Gecko/20100101 Firefox/121.0",
+			UserAgentInfo{Browser: "Firefox", BrowserVersion: "121", OS: "Mac OS X", DeviceType: DeviceDesktop},
+		},
+		{
+			"iPhone Safari",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			UserAgentInfo{Browser: "Safari", BrowserVersion: "17", OS: "iOS", DeviceType: DeviceMobile},
+		},
+		{
+			"iPad Safari",
+			"Mozilla/5.0 (iPad; CPU OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			UserAgentInfo{Browser: "Safari", BrowserVersion: "17", OS: "iOS", DeviceType: DeviceTablet},
+		},
+		{
+			"Android手机",
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			UserAgentInfo{Browser: "Chrome", BrowserVersion: "120", OS: "Android", DeviceType: DeviceMobile},
+		},
+		{
+			"Android平板",
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Safari/537.36",
+			UserAgentInfo{Browser: "Chrome", BrowserVersion: "120", OS: "Android", DeviceType: DeviceTablet},
+		},
+		{
+			"Googlebot",
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			UserAgentInfo{Browser: "Googlebot", BrowserVersion: "2", DeviceType: DeviceBot},
+		},
+		{
+			"空User-Agent视为爬虫",
+			"   ",
+			UserAgentInfo{DeviceType: DeviceBot},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseUserAgent(tt.raw); got != tt.want {
+				t.Errorf("ParseUserAgent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"blog-server/config"
+	"blog-server/models"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IP地址的保存方式
+const (
+	IPStorageNone     = "none"     // 不保存IP（默认）
+	IPStorageTruncate = "truncate" // 只保存网段：IPv4保留前24位，IPv6保留前48位
+)
+
+// visitorSaltTTL 盐值在Redis中的保留时间，过期后当天的访客ID无法再由IP反推
+// 保留到次日以便凌晨转存时补算旧数据的访客ID
+const visitorSaltTTL = 48 * time.Hour
+
+// clientIDPattern 跟踪脚本生成并保存在浏览器中的客户端ID
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+var (
+	visitorSaltMu    sync.Mutex
+	visitorSaltCache = make(map[string]string)
+)
+
+// VisitorID 由当天的随机盐、IP和User-Agent哈希生成访客ID
+// 盐每天更换且不落库，同一访客跨天的ID不同，也无法由ID还原IP
+func VisitorID(ctx context.Context, date, ipAddress, userAgentHash string) (string, error) {
+	salt, err := visitorSalt(ctx, date)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(salt + "|" + ipAddress + "|" + userAgentHash))
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// ClientID 将跟踪脚本上报的客户端ID转换为保存的匿名ID，格式不符时返回空字符串
+// 客户端ID跨天不变，用于留存分析；保存的是以CLIENT_ID_SECRET为密钥的HMAC，不能与浏览器中的值直接对应
+func ClientID(raw string) string {
+	if !clientIDPattern.MatchString(raw) {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.ClientIDSecret))
+	mac.Write([]byte(raw))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// SessionID 获取访客当前的会话ID，超过VisitIdleTimeout没有新事件时开始新的会话
+// 会话ID随机生成，与访客ID、IP无关，每收到一个事件顺延过期时间
+func SessionID(ctx context.Context, visitorID string) (string, error) {
+	key := "session:" + visitorID
+	sessionID := newSessionID()
+	created, err := RedisClient.SetNX(ctx, key, sessionID, models.VisitIdleTimeout).Result()
+	if err != nil {
+		return "", fmt.Errorf("保存会话失败: %v", err)
+	}
+	if created {
+		return sessionID, nil
+	}
+
+	existing, err := RedisClient.Get(ctx, key).Result()
+	if err == redis.Nil {
+		// 会话恰好在两次操作之间过期
+		return sessionID, RedisClient.Set(ctx, key, sessionID, models.VisitIdleTimeout).Err()
+	}
+	if err != nil {
+		return "", fmt.Errorf("读取会话失败: %v", err)
+	}
+	RedisClient.Expire(ctx, key, models.VisitIdleTimeout)
+	return existing, nil
+}
+
+// assignSessions 按访客和时间为没有会话ID的数据划分会话，间隔超过VisitIdleTimeout时开始新的会话
+func assignSessions(trackingDataList []TrackingData, indexes []int) {
+	sort.Slice(indexes, func(i, j int) bool {
+		a, b := trackingDataList[indexes[i]], trackingDataList[indexes[j]]
+		if a.VisitorID != b.VisitorID {
+			return a.VisitorID < b.VisitorID
+		}
+		return a.Timestamp.Before(b.Timestamp)
+	})
+
+	var previous *TrackingData
+	sessionID := ""
+	for _, i := range indexes {
+		data := &trackingDataList[i]
+		if previous == nil || previous.VisitorID != data.VisitorID ||
+			data.Timestamp.Sub(previous.Timestamp) > models.VisitIdleTimeout {
+			sessionID = newSessionID()
+		}
+		data.SessionID = sessionID
+		previous = data
+	}
+}
+
+// newSessionID 生成随机的会话ID
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// IPCounterKey 生成按IP计数的Redis键，IP用当天的访客盐做HMAC，键中不出现原始IP
+// 盐过期后无法再由键反推IP；获取盐失败时返回空字符串，调用方应跳过计数
+func IPCounterKey(ctx context.Context, prefix, ipAddress string) string {
+	salt, err := visitorSalt(ctx, time.Now().Format("2006-01-02"))
+	if err != nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(ipAddress))
+	return prefix + ":" + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// visitorSalt 获取指定日期的盐，多个实例通过Redis共享同一个盐
+func visitorSalt(ctx context.Context, date string) (string, error) {
+	visitorSaltMu.Lock()
+	defer visitorSaltMu.Unlock()
+
+	if salt, ok := visitorSaltCache[date]; ok {
+		return salt, nil
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("生成访客盐值失败: %v", err)
+	}
+
+	key := fmt.Sprintf("visitor_salt:%s", date)
+	if err := RedisClient.SetNX(ctx, key, hex.EncodeToString(random), visitorSaltTTL).Err(); err != nil {
+		return "", fmt.Errorf("保存访客盐值失败: %v", err)
+	}
+	salt, err := RedisClient.Get(ctx, key).Result()
+	if err != nil {
+		return "", fmt.Errorf("读取访客盐值失败: %v", err)
+	}
+
+	// 只缓存最近两天的盐，过期的盐随缓存一起丢弃
+	for cached := range visitorSaltCache {
+		if cached < time.Now().AddDate(0, 0, -1).Format("2006-01-02") {
+			delete(visitorSaltCache, cached)
+		}
+	}
+	visitorSaltCache[date] = salt
+	return salt, nil
+}
+
+// StoredIPAddress 按配置的方式处理要保存的IP地址
+func StoredIPAddress(ipAddress string) string {
+	if config.AppConfig.IPStorageMode != IPStorageTruncate {
+		return ""
+	}
+	return TruncateIP(ipAddress)
+}
+
+// TruncateIP 将IP截断为所在网段，IPv4保留前24位，IPv6保留前48位
+func TruncateIP(ipAddress string) string {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return ""
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}